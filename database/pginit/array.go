@@ -0,0 +1,47 @@
+package pginit
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgtype"
+)
+
+// nullableElement lets the elements of arrays and ranges be set from pointers,
+// a nil pointer being NULL, since the custom types only accept values.
+type nullableElement struct {
+	pgtype.ValueTranscoder
+}
+
+func nullable(newElement func() pgtype.ValueTranscoder) func() pgtype.ValueTranscoder {
+	return func() pgtype.ValueTranscoder {
+		return nullableElement{ValueTranscoder: newElement()}
+	}
+}
+
+func (dst nullableElement) Set(src interface{}) error {
+	if _, ok := src.(pgtype.Value); !ok {
+		if refVal := reflect.ValueOf(src); refVal.Kind() == reflect.Ptr {
+			src = nil
+			if !refVal.IsNil() {
+				src = refVal.Elem().Interface()
+			}
+		}
+	}
+
+	if err := dst.ValueTranscoder.Set(src); err != nil {
+		return fmt.Errorf("set element: %w", err)
+	}
+
+	return nil
+}
+
+// arrayDataType returns the one dimension array type, e.g. numeric[], of the elements created by newElement.
+// Slices of pointers are needed to scan arrays with NULL elements.
+func arrayDataType(name string, oid, elementOID uint32, newElement func() pgtype.ValueTranscoder) pgtype.DataType {
+	return pgtype.DataType{
+		Value: pgtype.NewArrayType(name, elementOID, nullable(newElement)),
+		Name:  name,
+		OID:   oid,
+	}
+}
@@ -0,0 +1,88 @@
+package pginit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestCustomTypes_ArrayAndRange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pool := newTestPool(t, pginit.WithDecimalType(), pginit.WithUUIDType())
+
+	t.Run("numeric array", func(t *testing.T) {
+		var decimals []decimal.Big
+		if err := pool.QueryRow(ctx, "SELECT ARRAY[1.5, -2]::numeric[]").Scan(&decimals); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if len(decimals) != 2 || decimals[0].Cmp(decimal.New(15, 1)) != 0 || decimals[1].Cmp(decimal.New(-2, 0)) != 0 {
+			t.Errorf("expected [1.5 -2] but got %v", decimals)
+		}
+
+		var withNull []*decimal.Big
+		if err := pool.QueryRow(ctx, "SELECT $1::numeric[]", []*decimal.Big{decimal.New(15, 1), nil}).Scan(&withNull); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if len(withNull) != 2 || withNull[0].Cmp(decimal.New(15, 1)) != 0 || withNull[1] != nil {
+			t.Errorf("expected [1.5 <nil>] but got %v", withNull)
+		}
+
+		if err := pool.QueryRow(ctx, "SELECT ARRAY[1, NULL]::numeric[]").Scan(&decimals); err == nil {
+			t.Error("expected error scanning a NULL element into a decimal.Big")
+		}
+	})
+
+	t.Run("uuid array", func(t *testing.T) {
+		expected := uuid.Must(uuid.FromString("b7202eb0-5bf0-475d-8ee2-d3d2c168a5d5"))
+
+		var uuids []*uuid.UUID
+		if err := pool.QueryRow(ctx, "SELECT $1::uuid[]", []*uuid.UUID{&expected, nil}).Scan(&uuids); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if len(uuids) != 2 || *uuids[0] != expected || uuids[1] != nil {
+			t.Errorf("expected [%s <nil>] but got %v", expected, uuids)
+		}
+	})
+
+	t.Run("numrange", func(t *testing.T) {
+		src := pginit.Numrange[decimal.Big]{
+			Lower:     *decimal.New(15, 1),
+			LowerType: pgtype.Inclusive,
+			UpperType: pgtype.Unbounded,
+			Status:    pgtype.Present,
+		}
+
+		var dst pginit.Numrange[decimal.Big]
+		if err := pool.QueryRow(ctx, "SELECT $1::numrange", src).Scan(&dst); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if dst.Status != pgtype.Present || dst.LowerType != pgtype.Inclusive || dst.UpperType != pgtype.Unbounded ||
+			dst.Lower.Cmp(&src.Lower) != 0 {
+			t.Errorf("expected %+v but got %+v", src, dst)
+		}
+
+		if err := pool.QueryRow(ctx, "SELECT 'empty'::numrange").Scan(&dst); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if dst.LowerType != pgtype.Empty {
+			t.Errorf("expected empty range but got %+v", dst)
+		}
+
+		var null *pginit.Numrange[decimal.Big]
+		if err := pool.QueryRow(ctx, "SELECT NULL::numrange").Scan(&null); err != nil || null != nil {
+			t.Errorf("expected nil range but got %v, %v", null, err)
+		}
+	})
+}
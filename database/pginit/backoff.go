@@ -0,0 +1,51 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const maxBackoffShift = 16
+
+// Backoff returns the delay to wait before the given retry attempt (starting at 0),
+// doubling base at each attempt up to max and picking a random delay in the upper half
+// of it so that concurrent clients do not retry in lockstep.
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for ; attempt > 0 && delay < max && delay <= math.MaxInt64/2; attempt-- {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2 // nolint: gomnd // upper half of the window
+
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // nolint: gosec // jitter does not need crypto rand
+}
+
+// jitteredBackoff is Backoff doubling base at most 16 times.
+func jitteredBackoff(base time.Duration, attempt int) time.Duration {
+	return Backoff(base, base<<maxBackoffShift, attempt)
+}
+
+// sleepContext waits for delay or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("wait: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
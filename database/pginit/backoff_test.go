@@ -0,0 +1,67 @@
+package pginit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		base     time.Duration
+		max      time.Duration
+		attempt  int
+		expected time.Duration
+	}{
+		{
+			name:     "first attempt waits base",
+			base:     time.Second,
+			max:      time.Hour,
+			attempt:  0,
+			expected: time.Second,
+		},
+		{
+			name:     "base is doubled at each attempt",
+			base:     time.Second,
+			max:      time.Hour,
+			attempt:  3,
+			expected: 8 * time.Second,
+		},
+		{
+			name:     "delay is capped by max",
+			base:     time.Second,
+			max:      time.Minute,
+			attempt:  10,
+			expected: time.Minute,
+		},
+		{
+			name:     "many attempts do not overflow",
+			base:     time.Second,
+			max:      time.Hour,
+			attempt:  1000,
+			expected: time.Hour,
+		},
+		{
+			name:     "no base, no delay",
+			max:      time.Hour,
+			attempt:  3,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			delay := pginit.Backoff(tt.base, tt.max, tt.attempt)
+			if delay < tt.expected/2 || delay > tt.expected {
+				t.Errorf("expected a delay between %v and %v but got %v", tt.expected/2, tt.expected, delay)
+			}
+		})
+	}
+}
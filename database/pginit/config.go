@@ -1,15 +1,261 @@
 package pginit
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is returned when a Config cannot be loaded or does not validate.
+var ErrInvalidConfig = errors.New("invalid config")
 
 // Config allow you to set database credential to connect to database
 type Config struct {
-	User         string
-	Password     string
-	Host         string
-	Port         string
-	Database     string
-	MaxConns     int32
+	User     string
+	Password string
+	Host     string
+	// Port is the port of Host, default 5432.
+	Port     string
+	Database string
+	// MaxConns is the maximum size of the pool, default 25.
+	MaxConns int32
+	// MinConns is the number of connections kept open even when idle, default 0.
+	MinConns int32
+	// MaxIdleConns caps the idle connections: a connection released while MaxIdleConns
+	// connections are already idle is closed. Default MaxConns.
+	// It is a soft cap, connections released at the same time can exceed it briefly.
 	MaxIdleConns int32
-	MaxLifeTime  time.Duration
+	// MaxLifeTime is the duration after which a connection is closed, default 5 minutes.
+	MaxLifeTime time.Duration
+	// MaxConnLifetimeJitter is a random duration added to MaxLifeTime for each connection,
+	// so that connections opened together are not all closed at the same time.
+	MaxConnLifetimeJitter time.Duration
+	// MaxConnIdleTime is the duration after which an idle connection is closed, default 30 minutes.
+	MaxConnIdleTime time.Duration
+	// HealthCheckPeriod is how often idle connections are checked, default 1 minute.
+	HealthCheckPeriod time.Duration
+	// LazyConnect makes ConnPool return without opening any connection.
+	LazyConnect bool
+	// Replicas are read-only endpoints sharing the credentials and database of the primary,
+	// they are only used by the Router returned by PGInit.RouterPool.
+	Replicas []Replica
+}
+
+// Replica is the address of a read replica of the primary database.
+type Replica struct {
+	Host string
+	// Port is the port of Host, default 5432.
+	Port string
+}
+
+// SecretBinder binds secrets into a value, it is implemented by secrets.SecretUrn.
+type SecretBinder interface {
+	Bind(v any) error
+}
+
+// Validate checks that the Config is usable, instead of silently falling back to defaults.
+func (conf *Config) Validate() error {
+	switch {
+	case conf.Host == "":
+		return fmt.Errorf("%w: host is required", ErrInvalidConfig)
+	case conf.MaxConns < 0, conf.MinConns < 0, conf.MaxIdleConns < 0:
+		return fmt.Errorf("%w: connection counts cannot be negative", ErrInvalidConfig)
+	case conf.MaxLifeTime < 0, conf.MaxConnLifetimeJitter < 0, conf.MaxConnIdleTime < 0, conf.HealthCheckPeriod < 0:
+		return fmt.Errorf("%w: durations cannot be negative", ErrInvalidConfig)
+	}
+
+	if err := validatePort(conf.Port); err != nil {
+		return err
+	}
+
+	maxConns := conf.MaxConns
+	if maxConns == 0 {
+		maxConns = defaultMaxConns
+	}
+
+	if conf.MinConns > maxConns {
+		return fmt.Errorf("%w: min conns (%d) greater than max conns (%d)", ErrInvalidConfig, conf.MinConns, maxConns)
+	}
+
+	if conf.MaxIdleConns > maxConns {
+		return fmt.Errorf("%w: max idle conns (%d) greater than max conns (%d)", ErrInvalidConfig, conf.MaxIdleConns, maxConns)
+	}
+
+	if conf.MaxIdleConns != 0 && conf.MaxIdleConns < conf.MinConns {
+		return fmt.Errorf(
+			"%w: max idle conns (%d) lower than min conns (%d)", ErrInvalidConfig, conf.MaxIdleConns, conf.MinConns,
+		)
+	}
+
+	for _, replica := range conf.Replicas {
+		if replica.Host == "" {
+			return fmt.Errorf("%w: replica host is required", ErrInvalidConfig)
+		}
+
+		if err := validatePort(replica.Port); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// portOrDefault returns port, or the default port of Postgres when it is empty.
+func portOrDefault(port string) string {
+	if port == "" {
+		return defaultPort
+	}
+
+	return port
+}
+
+func validatePort(port string) error {
+	if port == "" {
+		return nil
+	}
+
+	if _, err := strconv.ParseUint(port, base10, bitSize16); err != nil {
+		return fmt.Errorf("%w: port %q: %s", ErrInvalidConfig, port, err.Error())
+	}
+
+	return nil
+}
+
+// ConfigFromEnv loads a Config from the environment variables named after the keys
+// of ConfigFromYAML, upper cased and prefixed with prefix, e.g. PG_HOST or PG_MAX_CONNS for prefix "PG_".
+// Replicas are given as a comma separated list of host:port.
+func ConfigFromEnv(prefix string) (*Config, error) {
+	return loadConfig(func(key string) (string, bool) {
+		return os.LookupEnv(prefix + strings.ToUpper(key))
+	})
+}
+
+// ConfigFromYAML loads a Config from a YAML document with the keys
+// host, port, user, password, database, max_conns, min_conns, max_idle_conns,
+// max_life_time, max_conn_lifetime_jitter, max_conn_idle_time, health_check_period,
+// lazy_connect and replicas (a list of host:port). Durations are written like 5m or 30s.
+func ConfigFromYAML(r io.Reader) (*Config, error) {
+	values := map[string]interface{}{}
+
+	if err := yaml.NewDecoder(r).Decode(&values); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: decode yaml: %s", ErrInvalidConfig, err.Error())
+	}
+
+	return loadConfigFromMap(values)
+}
+
+// ConfigFromSecrets loads a Config from secrets, e.g. a secrets.SecretUrn,
+// holding the keys of ConfigFromYAML.
+func ConfigFromSecrets(secrets SecretBinder) (*Config, error) {
+	values := map[string]interface{}{}
+
+	if err := secrets.Bind(&values); err != nil {
+		return nil, fmt.Errorf("%w: bind secrets: %s", ErrInvalidConfig, err.Error())
+	}
+
+	return loadConfigFromMap(values)
+}
+
+func loadConfigFromMap(values map[string]interface{}) (*Config, error) {
+	return loadConfig(func(key string) (string, bool) {
+		value, ok := values[key]
+		if !ok || value == nil {
+			return "", false
+		}
+
+		if list, isList := value.([]interface{}); isList {
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				items = append(items, fmt.Sprint(item))
+			}
+
+			return strings.Join(items, ","), true
+		}
+
+		return fmt.Sprint(value), true
+	})
+}
+
+// nolint: cyclop // one branch per field
+func loadConfig(lookup func(key string) (string, bool)) (*Config, error) {
+	conf := &Config{}
+
+	strs := map[string]*string{
+		"host":     &conf.Host,
+		"port":     &conf.Port,
+		"user":     &conf.User,
+		"password": &conf.Password,
+		"database": &conf.Database,
+	}
+	for key, field := range strs {
+		if value, ok := lookup(key); ok {
+			*field = value
+		}
+	}
+
+	counts := map[string]*int32{
+		"max_conns":      &conf.MaxConns,
+		"min_conns":      &conf.MinConns,
+		"max_idle_conns": &conf.MaxIdleConns,
+	}
+	for key, field := range counts {
+		if value, ok := lookup(key); ok {
+			count, err := strconv.ParseInt(value, base10, bitSize32)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, key, err.Error())
+			}
+
+			*field = int32(count)
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"max_life_time":            &conf.MaxLifeTime,
+		"max_conn_lifetime_jitter": &conf.MaxConnLifetimeJitter,
+		"max_conn_idle_time":       &conf.MaxConnIdleTime,
+		"health_check_period":      &conf.HealthCheckPeriod,
+	}
+	for key, field := range durations {
+		if value, ok := lookup(key); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, key, err.Error())
+			}
+
+			*field = duration
+		}
+	}
+
+	if value, ok := lookup("lazy_connect"); ok {
+		lazy, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: lazy_connect: %s", ErrInvalidConfig, err.Error())
+		}
+
+		conf.LazyConnect = lazy
+	}
+
+	if value, ok := lookup("replicas"); ok && value != "" {
+		for _, address := range strings.Split(value, ",") {
+			host, port, err := net.SplitHostPort(strings.TrimSpace(address))
+			if err != nil {
+				return nil, fmt.Errorf("%w: replica %q: %s", ErrInvalidConfig, address, err.Error())
+			}
+
+			conf.Replicas = append(conf.Replicas, Replica{Host: host, Port: port})
+		}
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
 }
@@ -0,0 +1,276 @@
+package pginit_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+type fakeSecretBinder map[string]any
+
+func (s fakeSecretBinder) Bind(v any) error {
+	values, ok := v.(*map[string]interface{})
+	if !ok {
+		return errSecretNotFound
+	}
+
+	for key, value := range s {
+		(*values)[key] = value
+	}
+
+	return nil
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		config    pginit.Config
+		expectErr bool
+	}{
+		{
+			name:      "minimal config",
+			config:    pginit.Config{Host: "localhost", Port: "5432"},
+			expectErr: false,
+		},
+		{
+			name:      "missing host",
+			config:    pginit.Config{Port: "5432"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid port",
+			config:    pginit.Config{Host: "localhost", Port: "postgres"},
+			expectErr: true,
+		},
+		{
+			name:      "negative max conns",
+			config:    pginit.Config{Host: "localhost", Port: "5432", MaxConns: -1},
+			expectErr: true,
+		},
+		{
+			name:      "min conns greater than default max conns",
+			config:    pginit.Config{Host: "localhost", Port: "5432", MinConns: 30},
+			expectErr: true,
+		},
+		{
+			name:      "max idle conns greater than max conns",
+			config:    pginit.Config{Host: "localhost", Port: "5432", MaxConns: 10, MaxIdleConns: 11},
+			expectErr: true,
+		},
+		{
+			name:      "max idle conns lower than min conns",
+			config:    pginit.Config{Host: "localhost", Port: "5432", MinConns: 5, MaxIdleConns: 2},
+			expectErr: true,
+		},
+		{
+			name:      "negative duration",
+			config:    pginit.Config{Host: "localhost", Port: "5432", MaxConnIdleTime: -time.Second},
+			expectErr: true,
+		},
+		{
+			name:      "missing ports default to 5432",
+			config:    pginit.Config{Host: "localhost", Replicas: []pginit.Replica{{Host: "replica"}}},
+			expectErr: false,
+		},
+		{
+			name: "replica with invalid port",
+			config: pginit.Config{
+				Host:     "localhost",
+				Port:     "5432",
+				Replicas: []pginit.Replica{{Host: "replica", Port: "-1"}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.config.Validate()
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error to be %t but got: %v", tt.expectErr, err)
+			}
+
+			if err != nil && !errors.Is(err, pginit.ErrInvalidConfig) {
+				t.Errorf("expected (%v) but got (%v)", pginit.ErrInvalidConfig, err)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := pginit.New(&pginit.Config{Host: "localhost", Port: "5432", MaxConns: 5, MinConns: 10})
+	if !errors.Is(err, pginit.ErrInvalidConfig) {
+		t.Errorf("expected (%v) but got (%v)", pginit.ErrInvalidConfig, err)
+	}
+}
+
+func expectedLoadedConfig() *pginit.Config {
+	return &pginit.Config{
+		User:                  "postgres",
+		Password:              "secret",
+		Host:                  "primary",
+		Port:                  "5432",
+		Database:              "datawarehouse",
+		MaxConns:              10,
+		MinConns:              2,
+		MaxIdleConns:          5,
+		MaxLifeTime:           time.Hour,
+		MaxConnLifetimeJitter: time.Minute,
+		MaxConnIdleTime:       10 * time.Minute,
+		HealthCheckPeriod:     30 * time.Second,
+		LazyConnect:           true,
+		Replicas: []pginit.Replica{
+			{Host: "replica-1", Port: "5432"},
+			{Host: "replica-2", Port: "5433"},
+		},
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"PG_HOST":                     "primary",
+		"PG_PORT":                     "5432",
+		"PG_USER":                     "postgres",
+		"PG_PASSWORD":                 "secret",
+		"PG_DATABASE":                 "datawarehouse",
+		"PG_MAX_CONNS":                "10",
+		"PG_MIN_CONNS":                "2",
+		"PG_MAX_IDLE_CONNS":           "5",
+		"PG_MAX_LIFE_TIME":            "1h",
+		"PG_MAX_CONN_LIFETIME_JITTER": "1m",
+		"PG_MAX_CONN_IDLE_TIME":       "10m",
+		"PG_HEALTH_CHECK_PERIOD":      "30s",
+		"PG_LAZY_CONNECT":             "true",
+		"PG_REPLICAS":                 "replica-1:5432, replica-2:5433",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	conf, err := pginit.ConfigFromEnv("PG_")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if want := expectedLoadedConfig(); !reflect.DeepEqual(conf, want) {
+		t.Errorf("expected (%+v) but got (%+v)", want, conf)
+	}
+
+	t.Setenv("PG_MAX_CONNS", "ten")
+
+	if _, err := pginit.ConfigFromEnv("PG_"); !errors.Is(err, pginit.ErrInvalidConfig) {
+		t.Errorf("expected (%v) but got (%v)", pginit.ErrInvalidConfig, err)
+	}
+}
+
+func TestConfigFromYAML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		yaml      string
+		want      *pginit.Config
+		expectErr bool
+	}{
+		{
+			name: "all keys",
+			yaml: `
+host: primary
+port: 5432
+user: postgres
+password: secret
+database: datawarehouse
+max_conns: 10
+min_conns: 2
+max_idle_conns: 5
+max_life_time: 1h
+max_conn_lifetime_jitter: 1m
+max_conn_idle_time: 10m
+health_check_period: 30s
+lazy_connect: true
+replicas:
+  - replica-1:5432
+  - replica-2:5433
+`,
+			want: expectedLoadedConfig(),
+		},
+		{
+			name:      "invalid duration",
+			yaml:      "host: primary\nport: 5432\nmax_life_time: forever\n",
+			expectErr: true,
+		},
+		{
+			name:      "max idle conns greater than max conns",
+			yaml:      "host: primary\nport: 5432\nmax_conns: 2\nmax_idle_conns: 3\n",
+			expectErr: true,
+		},
+		{
+			name:      "empty document",
+			yaml:      "",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf, err := pginit.ConfigFromYAML(strings.NewReader(tt.yaml))
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error to be %t but got: %v", tt.expectErr, err)
+			}
+
+			if err != nil {
+				if !errors.Is(err, pginit.ErrInvalidConfig) {
+					t.Errorf("expected (%v) but got (%v)", pginit.ErrInvalidConfig, err)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(conf, tt.want) {
+				t.Errorf("expected (%+v) but got (%+v)", tt.want, conf)
+			}
+		})
+	}
+}
+
+func TestConfigFromSecrets(t *testing.T) {
+	t.Parallel()
+
+	conf, err := pginit.ConfigFromSecrets(fakeSecretBinder{
+		"host":                     "primary",
+		"port":                     "5432",
+		"user":                     "postgres",
+		"password":                 "secret",
+		"database":                 "datawarehouse",
+		"max_conns":                "10",
+		"min_conns":                "2",
+		"max_idle_conns":           "5",
+		"max_life_time":            "1h",
+		"max_conn_lifetime_jitter": "1m",
+		"max_conn_idle_time":       "10m",
+		"health_check_period":      "30s",
+		"lazy_connect":             "true",
+		"replicas":                 "replica-1:5432,replica-2:5433",
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if want := expectedLoadedConfig(); !reflect.DeepEqual(conf, want) {
+		t.Errorf("expected (%+v) but got (%+v)", want, conf)
+	}
+}
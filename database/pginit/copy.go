@@ -0,0 +1,124 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// CopyFromer copies rows with COPY FROM STDIN, it is implemented by *pgxpool.Pool, *pgx.Conn, pgx.Tx and *Router.
+type CopyFromer interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// CopyStructs copies rows into table with COPY ... FROM STDIN (FORMAT binary) and returns the number of rows copied.
+// Rows are structs, or pointers to structs, whose fields tagged db:"column" are copied into the columns of the same name.
+// Field values are encoded by the data types registered on the connection,
+// so decimal.Big and uuid.UUID fields need WithDecimalType and WithUUIDType.
+func CopyStructs[T any](ctx context.Context, db CopyFromer, table pgx.Identifier, rows []T) (int64, error) {
+	idx := 0
+
+	return copyStructs(ctx, db, table, func() (T, bool) {
+		if idx >= len(rows) {
+			var zero T
+
+			return zero, false
+		}
+
+		idx++
+
+		return rows[idx-1], true
+	})
+}
+
+// CopyChan copies the rows received from rows, until it is closed, like CopyStructs.
+// The copy is aborted when ctx is cancelled, even while waiting for a row, and nothing is copied.
+func CopyChan[T any](ctx context.Context, db CopyFromer, table pgx.Identifier, rows <-chan T) (int64, error) {
+	return copyStructs(ctx, db, table, func() (T, bool) {
+		select {
+		case row, ok := <-rows:
+			return row, ok
+		case <-ctx.Done():
+			var zero T
+
+			return zero, false
+		}
+	})
+}
+
+func copyStructs[T any](ctx context.Context, db CopyFromer, table pgx.Identifier, next func() (T, bool)) (int64, error) {
+	fields, err := structFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return 0, fmt.Errorf("copy from: %w", err)
+	}
+
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+
+	src := &structSource[T]{ctx: ctx, next: next, fields: fields}
+
+	copied, err := db.CopyFrom(ctx, table, columns, src)
+
+	// the source error is more telling than the one returned by the aborted copy
+	if src.err != nil {
+		return copied, fmt.Errorf("copy from: %w", src.err)
+	}
+
+	if err != nil {
+		return copied, fmt.Errorf("copy from: %w", err)
+	}
+
+	return copied, nil
+}
+
+// structSource is a pgx.CopyFromSource of structs that stops when its context is cancelled.
+type structSource[T any] struct {
+	ctx    context.Context // nolint: containedctx // checked between rows
+	next   func() (T, bool)
+	fields []structField
+	row    T
+	err    error
+}
+
+func (s *structSource[T]) Next() bool {
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+
+		return false
+	}
+
+	row, ok := s.next()
+	if !ok {
+		s.err = s.ctx.Err()
+
+		return false
+	}
+
+	s.row = row
+
+	return true
+}
+
+func (s *structSource[T]) Values() ([]interface{}, error) {
+	value, err := structValue(s.row)
+	if err != nil {
+		s.err = err
+
+		return nil, err
+	}
+
+	values := make([]interface{}, len(s.fields))
+	for i, field := range s.fields {
+		values[i] = value.FieldByIndex(field.index).Interface()
+	}
+
+	return values, nil
+}
+
+func (s *structSource[T]) Err() error {
+	return s.err
+}
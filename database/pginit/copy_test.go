@@ -0,0 +1,151 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+type copyBase struct {
+	ID uuid.UUID `db:"id"`
+}
+
+type copyRow struct {
+	copyBase
+	Amount   *decimal.Big `db:"amount"`
+	Note     string       `db:"note,omitempty"`
+	Ignored  string       `db:"-"`
+	Untagged string
+}
+
+// fakeCopyFromer drains the source like pgx does.
+type fakeCopyFromer struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func (f *fakeCopyFromer) CopyFrom(
+	ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	f.columns = columnNames
+
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+
+		f.rows = append(f.rows, values)
+	}
+
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(f.rows)), nil
+}
+
+func TestCopyStructs_Mapping(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.Must(uuid.NewV4())
+	amount := decimal.New(15, 1)
+
+	db := &fakeCopyFromer{}
+
+	copied, err := pginit.CopyStructs(context.Background(), db, pgx.Identifier{"payments"}, []*copyRow{
+		{copyBase: copyBase{ID: id}, Amount: amount, Note: "a", Ignored: "x", Untagged: "y"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if copied != 1 {
+		t.Errorf("expected 1 row copied but got %d", copied)
+	}
+
+	if expected := []string{"id", "amount", "note"}; !reflect.DeepEqual(db.columns, expected) {
+		t.Errorf("expected columns %v but got %v", expected, db.columns)
+	}
+
+	if expected := []interface{}{id, amount, "a"}; !reflect.DeepEqual(db.rows[0], expected) {
+		t.Errorf("expected values %v but got %v", expected, db.rows[0])
+	}
+
+	if _, err := pginit.CopyStructs(context.Background(), db, pgx.Identifier{"payments"}, []*copyRow{nil}); err == nil {
+		t.Error("expected error for a nil row")
+	}
+
+	if _, err := pginit.CopyStructs(context.Background(), db, pgx.Identifier{"payments"}, []int{1}); err == nil {
+		t.Error("expected error for rows that are not structs")
+	}
+}
+
+func TestCopyChan_Cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rows := make(chan copyRow, 1)
+	rows <- copyRow{Note: "a"}
+
+	go func() {
+		// the channel is never closed, only cancelling stops the copy
+		cancel()
+	}()
+
+	_, err := pginit.CopyChan(ctx, &fakeCopyFromer{}, pgx.Identifier{"payments"}, rows)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got: %v", err)
+	}
+}
+
+func TestCopyStructs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pool := newTestPool(t, pginit.WithDecimalType(), pginit.WithUUIDType())
+
+	if _, err := pool.Exec(ctx,
+		"CREATE TABLE IF NOT EXISTS copy_payments (id uuid PRIMARY KEY, amount numeric(20, 2), note text)",
+	); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	rows := make(chan copyRow)
+
+	go func() {
+		defer close(rows)
+
+		for i := 0; i < 100; i++ {
+			rows <- copyRow{copyBase: copyBase{ID: uuid.Must(uuid.NewV4())}, Amount: decimal.New(int64(i), 2)}
+		}
+
+		rows <- copyRow{copyBase: copyBase{ID: uuid.Must(uuid.NewV4())}, Note: "null amount"}
+	}()
+
+	copied, err := pginit.CopyChan(ctx, pool, pgx.Identifier{"copy_payments"}, rows)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if copied != 101 {
+		t.Errorf("expected 101 rows copied but got %d", copied)
+	}
+
+	var sum decimal.Big
+	if err := pool.QueryRow(ctx, "SELECT sum(amount) FROM copy_payments").Scan(&sum); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if sum.Cmp(decimal.New(4950, 2)) != 0 {
+		t.Errorf("expected 49.50 but got %s", sum.String())
+	}
+}
@@ -0,0 +1,106 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Credentials are the user and password used to open a connection.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// CredentialProvider returns the credentials to open a new connection with.
+type CredentialProvider func(ctx context.Context) (Credentials, error)
+
+// SecretGetter reads a string secret by key, it is implemented by secrets.SecretUrn.
+type SecretGetter interface {
+	GetSecretString(key string) (string, error)
+}
+
+// WithCredentialProvider makes every new physical connection use the credentials returned
+// by provider instead of Config.User and Config.Password, so that rotated passwords
+// are used without recreating the pool. Existing connections are not closed,
+// they are replaced as they reach MaxLifeTime.
+// An empty User in the returned Credentials keeps Config.User.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(pgi *PGInit) {
+		pgi.beforeConnect = append(pgi.beforeConnect, func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			creds, err := provider(ctx)
+			if err != nil {
+				return fmt.Errorf("credential provider: %w", err)
+			}
+
+			if creds.User != "" {
+				connConfig.User = creds.User
+			}
+
+			connConfig.Password = creds.Password
+
+			return nil
+		})
+	}
+}
+
+// SecretsCredentialProvider returns a CredentialProvider reading passwordKey, and userKey
+// if not empty, from the secrets returned by load, e.g. a secrets.SecretUrn
+// fetched from AWS Secrets Manager.
+func SecretsCredentialProvider(
+	load func(ctx context.Context) (SecretGetter, error),
+	userKey, passwordKey string,
+) CredentialProvider {
+	return func(ctx context.Context) (Credentials, error) {
+		secrets, err := load(ctx)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("load secrets: %w", err)
+		}
+
+		var creds Credentials
+
+		if userKey != "" {
+			if creds.User, err = secrets.GetSecretString(userKey); err != nil {
+				return Credentials{}, fmt.Errorf("secret %s: %w", userKey, err)
+			}
+		}
+
+		if creds.Password, err = secrets.GetSecretString(passwordKey); err != nil {
+			return Credentials{}, fmt.Errorf("secret %s: %w", passwordKey, err)
+		}
+
+		return creds, nil
+	}
+}
+
+// CachedCredentialProvider returns a CredentialProvider calling provider at most once per ttl,
+// to avoid calling a secrets manager each time the pool opens a connection.
+// Errors are not cached.
+func CachedCredentialProvider(provider CredentialProvider, ttl time.Duration) CredentialProvider {
+	var (
+		mu        sync.Mutex
+		cached    Credentials
+		expiresAt time.Time
+	)
+
+	return func(ctx context.Context) (Credentials, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Now().Before(expiresAt) {
+			return cached, nil
+		}
+
+		creds, err := provider(ctx)
+		if err != nil {
+			return Credentials{}, err
+		}
+
+		cached, expiresAt = creds, time.Now().Add(ttl)
+
+		return creds, nil
+	}
+}
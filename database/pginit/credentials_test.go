@@ -0,0 +1,172 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+var errSecretNotFound = errors.New("secret not found")
+
+type fakeSecrets map[string]string
+
+func (s fakeSecrets) GetSecretString(key string) (string, error) {
+	value, ok := s[key]
+	if !ok {
+		return "", errSecretNotFound
+	}
+
+	return value, nil
+}
+
+func TestWithCredentialProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		secrets   fakeSecrets
+		userKey   string
+		expectErr error
+	}{
+		{
+			name:    "password from secrets",
+			secrets: fakeSecrets{"db_password": "postgres"},
+		},
+		{
+			name:    "user and password from secrets",
+			secrets: fakeSecrets{"db_user": "postgres", "db_password": "postgres"},
+			userKey: "db_user",
+		},
+		{
+			name:      "missing secret",
+			secrets:   fakeSecrets{},
+			expectErr: errSecretNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var loads int32
+
+			provider := pginit.SecretsCredentialProvider(
+				func(ctx context.Context) (pginit.SecretGetter, error) {
+					atomic.AddInt32(&loads, 1)
+
+					return tt.secrets, nil
+				},
+				tt.userKey,
+				"db_password",
+			)
+
+			pgi, err := pginit.New(
+				&pginit.Config{
+					Host:     testHost,
+					Port:     testPort,
+					User:     "wrong",
+					Password: "wrong",
+					Database: "datawarehouse",
+					MaxConns: 2,
+				},
+				pginit.WithCredentialProvider(pginit.CachedCredentialProvider(provider, time.Minute)),
+			)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			ctx := context.Background()
+
+			pool, err := pgi.ConnPool(ctx)
+			if err == nil {
+				defer pool.Close()
+
+				err = pool.Ping(ctx)
+			}
+
+			if (tt.expectErr == nil && err != nil) || !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected (%v) but got (%v)", tt.expectErr, err)
+			}
+
+			if tt.expectErr != nil {
+				return
+			}
+
+			// open a second physical connection, the cache must avoid loading secrets again
+			conns := [2]interface{ Release() }{}
+			for i := range conns {
+				conn, err := pool.Acquire(ctx)
+				if err != nil {
+					t.Fatalf("expected no error but got: %v", err)
+				}
+
+				conns[i] = conn
+			}
+
+			for _, conn := range conns {
+				conn.Release()
+			}
+
+			if loads := atomic.LoadInt32(&loads); loads != 1 {
+				t.Errorf("expected secrets loaded once but got %d", loads)
+			}
+		})
+	}
+}
+
+func TestWithCredentialProvider_Rotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	adminPool := newTestPool(t)
+
+	if _, err := adminPool.Exec(ctx, "CREATE ROLE rotated LOGIN PASSWORD 'first'"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	password := atomic.Value{}
+	password.Store("first")
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "rotated",
+			Database: "datawarehouse",
+			MaxConns: 2,
+		},
+		pginit.WithCredentialProvider(func(ctx context.Context) (pginit.Credentials, error) {
+			return pginit.Credentials{Password: password.Load().(string)}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := adminPool.Exec(ctx, "ALTER ROLE rotated PASSWORD 'second'"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	password.Store("second")
+
+	// drop the existing connections so that new ones are opened with the rotated password
+	for _, conn := range pool.AcquireAllIdle(ctx) {
+		_ = conn.Conn().Close(ctx)
+		conn.Release()
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		t.Errorf("expected no error after rotation but got: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrTypeNotFound is returned on connect when a type registered with WithTypeByName does not exist.
+var ErrTypeNotFound = errors.New("type not found")
+
+// WithDataType registers dataType on every connection, for types with a fixed OID.
+// Types created per database, e.g. enums or extensions, should use WithTypeByName.
+func WithDataType(dataType pgtype.DataType) Option {
+	return func(pgi *PGInit) {
+		pgi.customDataTypes = append(pgi.customDataTypes, dataType)
+	}
+}
+
+type typeByName struct {
+	name  string
+	value pgtype.Value
+
+	mu  sync.Mutex
+	oid uint32
+}
+
+// WithTypeByName registers value for the type name, e.g. "citext", "hstore" or "public.mood",
+// whose OID is looked up in pg_type on the first connection and cached.
+// Connecting fails with ErrTypeNotFound if the type does not exist.
+func WithTypeByName(name string, value pgtype.Value) Option {
+	return func(pgi *PGInit) {
+		namedType := &typeByName{name: name, value: value}
+
+		pgi.afterConnect = append(pgi.afterConnect, namedType.register)
+	}
+}
+
+func (t *typeByName) register(ctx context.Context, conn *pgx.Conn) error {
+	oid, err := t.lookupOID(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	conn.ConnInfo().RegisterDataType(pgtype.DataType{Value: t.value, Name: t.name, OID: oid})
+
+	return nil
+}
+
+// lookupOID returns the cached OID of the type, errors are not cached.
+func (t *typeByName) lookupOID(ctx context.Context, conn *pgx.Conn) (uint32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.oid != 0 {
+		return t.oid, nil
+	}
+
+	var oid uint32
+	if err := conn.QueryRow(ctx, "SELECT coalesce(to_regtype($1)::oid, 0::oid)", t.name).Scan(&oid); err != nil {
+		return 0, fmt.Errorf("lookup type %s: %w", t.name, err)
+	}
+
+	if oid == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrTypeNotFound, t.name)
+	}
+
+	t.oid = oid
+
+	return t.oid, nil
+}
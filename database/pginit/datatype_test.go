@@ -0,0 +1,134 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	gofrs "github.com/jackc/pgtype/ext/gofrs-uuid"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestWithDataType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: 2,
+		},
+		pginit.WithDataType(pgtype.DataType{Value: &gofrs.UUID{}, Name: "uuid", OID: pgtype.UUIDOID}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	var u uuid.UUID
+	if err := pool.QueryRow(ctx, "SELECT 'b7202eb0-5bf0-475d-8ee2-d3d2c168a5d5'::uuid").Scan(&u); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if u.String() != "b7202eb0-5bf0-475d-8ee2-d3d2c168a5d5" {
+		t.Errorf("expected b7202eb0-5bf0-475d-8ee2-d3d2c168a5d5 but got %s", u)
+	}
+}
+
+func TestWithTypeByName(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conf := &pginit.Config{
+		Host:     testHost,
+		Port:     testPort,
+		User:     "postgres",
+		Password: "postgres",
+		Database: "datawarehouse",
+		MaxConns: 2,
+	}
+
+	setup, err := pginit.New(conf)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	setupPool, err := setup.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	for _, sql := range []string{
+		"CREATE EXTENSION IF NOT EXISTS hstore",
+		"DROP TYPE IF EXISTS pginit_mood",
+		"CREATE TYPE pginit_mood AS ENUM ('happy', 'sad')",
+	} {
+		if _, err := setupPool.Exec(ctx, sql); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+
+	setupPool.Close()
+
+	t.Run("registered types", func(t *testing.T) {
+		pgi, err := pginit.New(conf,
+			pginit.WithTypeByName("hstore", &pgtype.Hstore{}),
+			pginit.WithTypeByName("public.pginit_mood", pgtype.NewEnumType("pginit_mood", []string{"happy", "sad"})),
+		)
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		pool, err := pgi.ConnPool(ctx)
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		defer pool.Close()
+
+		var hstore map[string]string
+		if err := pool.QueryRow(ctx, "SELECT 'a=>1'::hstore").Scan(&hstore); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if hstore["a"] != "1" {
+			t.Errorf("expected a=>1 but got %v", hstore)
+		}
+
+		var mood string
+		if err := pool.QueryRow(ctx, "SELECT $1::pginit_mood", "sad").Scan(&mood); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if mood != "sad" {
+			t.Errorf("expected sad but got %s", mood)
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		pgi, err := pginit.New(conf, pginit.WithTypeByName("pginit_unknown", &pgtype.Text{}))
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		pool, err := pgi.ConnPool(ctx)
+		if err == nil {
+			pool.Close()
+		}
+
+		if !errors.Is(err, pginit.ErrTypeNotFound) {
+			t.Errorf("expected error %v but got %v", pginit.ErrTypeNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package pginit
+
+import (
+	apddecimal "github.com/cockroachdb/apd/v3"
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/apd"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/shopspring"
+	shopspringdecimal "github.com/shopspring/decimal"
+)
+
+// DecimalKind selects the decimal library numeric values are mapped to by WithDecimalType.
+type DecimalKind func() pgtype.ValueTranscoder
+
+// EricLagergren maps numeric to ericlagergren/decimal, scanning into ericlagergren.Numeric or decimal.Big.
+// opts set the rounding of the scanned values, e.g. ericlagergren.WithTypmod.
+func EricLagergren(opts ...ericlagergren.NumericOption) DecimalKind {
+	return func() pgtype.ValueTranscoder {
+		return ericlagergren.NewNumeric(opts...)
+	}
+}
+
+// Shopspring maps numeric to shopspring/decimal, scanning into shopspring.Numeric or decimal.Decimal.
+// NaN and Infinity cannot be scanned.
+func Shopspring() DecimalKind {
+	return func() pgtype.ValueTranscoder {
+		return &shopspring.Numeric{}
+	}
+}
+
+// APD maps numeric to cockroachdb/apd, scanning into apd.Numeric or apd.Decimal.
+func APD() DecimalKind {
+	return func() pgtype.ValueTranscoder {
+		return &apd.Numeric{}
+	}
+}
+
+// defaultPgTypes returns the Go types of the decimal library of kind, so that pgx finds their data type
+// when it encodes arguments without knowing the parameter types, as with the simple protocol.
+func (kind DecimalKind) defaultPgTypes() []defaultPgType {
+	switch kind().(type) {
+	case *ericlagergren.Numeric:
+		return decimalPgTypes[decimal.Big]()
+	case *shopspring.Numeric:
+		return decimalPgTypes[shopspringdecimal.Decimal]()
+	case *apd.Numeric:
+		return decimalPgTypes[apddecimal.Decimal]()
+	}
+
+	return nil
+}
+
+func decimalPgTypes[T any]() []defaultPgType {
+	return []defaultPgType{
+		{value: *new(T), name: "numeric"},
+		{value: []T{}, name: "_numeric"},
+		{value: []*T{}, name: "_numeric"},
+		{value: Numrange[T]{}, name: "numrange"},
+	}
+}
@@ -0,0 +1,86 @@
+package pginit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/apd/v3"
+	"github.com/ericlagergren/decimal"
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+	shopspring "github.com/shopspring/decimal"
+)
+
+func TestWithDecimalType_Kind(t *testing.T) {
+	t.Parallel()
+
+	var (
+		big    decimal.Big
+		shop   shopspring.Decimal
+		apdDec apd.Decimal
+	)
+
+	tests := []struct {
+		name string
+		kind pginit.DecimalKind
+		dst  interface{}
+		text func() string
+	}{
+		{
+			name: "ericlagergren",
+			kind: pginit.EricLagergren(ericlagergren.WithTypmod(10, 1)),
+			dst:  &big,
+			text: func() string { return big.String() },
+		},
+		{
+			name: "shopspring",
+			kind: pginit.Shopspring(),
+			dst:  &shop,
+			text: func() string { return shop.String() },
+		},
+		{
+			name: "apd",
+			kind: pginit.APD(),
+			dst:  &apdDec,
+			text: func() string { return apdDec.String() },
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			pgi, err := pginit.New(
+				&pginit.Config{
+					Host:     testHost,
+					Port:     testPort,
+					User:     "postgres",
+					Password: "postgres",
+					Database: "datawarehouse",
+					MaxConns: 2,
+				},
+				pginit.WithDecimalType(tt.kind),
+			)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			pool, err := pgi.ConnPool(ctx)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			defer pool.Close()
+
+			if err := pool.QueryRow(ctx, "SELECT 10.98::numeric(10, 1)").Scan(tt.dst); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			if got := tt.text(); got != "11.0" {
+				t.Errorf("expected 11.0 but got %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+// Package apd provides a pgtype codec mapping the Postgres numeric type to cockroachdb/apd.
+//
+// NaN, Infinity and -Infinity are mapped to the apd.NaN and apd.Infinite forms,
+// signaling NaNs are sent as NaN.
+package apd
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/cockroachdb/apd/v3"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/pgnumeric"
+)
+
+var (
+	ErrUndefined        = errors.New("cannot encode status undefined")
+	ErrBadStatus        = errors.New("invalid status")
+	errConversionFailed = errors.New("failed to convert")
+	errAssignFailed     = errors.New("failed to assign")
+	errScanFailed       = errors.New("failed to scan")
+)
+
+const (
+	base      = 10
+	bitSize8  = 8
+	bitSize16 = 16
+	bitSize32 = 32
+	bitSize64 = 64
+)
+
+// Numeric holds an apd.Decimal, which must not be shared between Numeric since it may
+// reference the same big.Int, use Decimal.Set to copy it.
+type Numeric struct {
+	Decimal apd.Decimal
+	Status  pgtype.Status
+}
+
+// nolint: cyclop // need to check each type to make sure all case is covered
+func (dst *Numeric) Set(src interface{}) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	if value, ok := src.(interface{ Get() interface{} }); ok {
+		value2 := value.Get()
+		if value2 != value {
+			return dst.Set(value2)
+		}
+	}
+
+	switch value := src.(type) {
+	case apd.Decimal:
+		*dst = Numeric{Status: pgtype.Present}
+		dst.Decimal.Set(&value)
+	case *apd.Decimal:
+		*dst = Numeric{Status: pgtype.Present}
+		dst.Decimal.Set(value)
+	case apd.NullDecimal:
+		if !value.Valid {
+			*dst = Numeric{Status: pgtype.Null}
+
+			return nil
+		}
+
+		*dst = Numeric{Status: pgtype.Present}
+		dst.Decimal.Set(&value.Decimal)
+	case float32:
+		dst.setFloat64(float64(value))
+	case float64:
+		dst.setFloat64(value)
+	case int8:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case uint8:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case int16:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case uint16:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case int32:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case uint32:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case int64:
+		*dst = Numeric{Decimal: *apd.New(value, 0), Status: pgtype.Present}
+	case uint64:
+		// uint64 could be greater than int64
+		*dst = Numeric{Status: pgtype.Present}
+		dst.Decimal.Coeff.SetUint64(value)
+	case int:
+		*dst = Numeric{Decimal: *apd.New(int64(value), 0), Status: pgtype.Present}
+	case uint:
+		*dst = Numeric{Status: pgtype.Present}
+		dst.Decimal.Coeff.SetUint64(uint64(value))
+	case string:
+		return dst.DecodeText(nil, []byte(value))
+	default:
+		// If all else fails see if pgtype.Numeric can handle it. If so, translate through that.
+		num := &pgtype.Numeric{}
+		if err := num.Set(value); err != nil {
+			return fmt.Errorf("cannot convert %v to Numeric: %w", value, err)
+		}
+
+		buf, err := num.EncodeText(nil, nil)
+		if err != nil {
+			return fmt.Errorf("cannot convert %v to Numeric: %w", value, err)
+		}
+
+		return dst.DecodeText(nil, buf)
+	}
+
+	return nil
+}
+
+func (dst *Numeric) setFloat64(value float64) {
+	*dst = Numeric{Status: pgtype.Present}
+
+	switch {
+	case math.IsNaN(value):
+		dst.Decimal.Form = apd.NaN
+	case math.IsInf(value, 0):
+		dst.Decimal.Form = apd.Infinite
+		dst.Decimal.Negative = value < 0
+	default:
+		// cannot fail for finite values
+		_, _ = dst.Decimal.SetFloat64(value)
+	}
+}
+
+func (dst *Numeric) Get() interface{} {
+	switch {
+	case dst.Status == pgtype.Present:
+		return dst.Decimal
+	case dst.Status == pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// nolint: cyclop, revive, stylecheck // need to check each type to make sure all case is covered
+func (src *Numeric) AssignTo(dst interface{}) error {
+	switch {
+	case src.Status == pgtype.Present:
+		switch val := dst.(type) {
+		case *apd.Decimal:
+			val.Set(&src.Decimal)
+		case *apd.NullDecimal:
+			val.Decimal.Set(&src.Decimal)
+			val.Valid = true
+		case *float32:
+			f, _ := src.Decimal.Float64()
+			*val = float32(f)
+		case *float64:
+			*val, _ = src.Decimal.Float64()
+		case *int:
+			n, err := src.parseInt(strconv.IntSize)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int(n)
+		case *int8:
+			n, err := src.parseInt(bitSize8)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int8(n)
+		case *int16:
+			n, err := src.parseInt(bitSize16)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int16(n)
+		case *int32:
+			n, err := src.parseInt(bitSize32)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int32(n)
+		case *int64:
+			n, err := src.parseInt(bitSize64)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = n
+		case *uint:
+			n, err := src.parseUint(strconv.IntSize)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint(n)
+		case *uint8:
+			n, err := src.parseUint(bitSize8)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint8(n)
+		case *uint16:
+			n, err := src.parseUint(bitSize16)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint16(n)
+		case *uint32:
+			n, err := src.parseUint(bitSize32)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint32(n)
+		case *uint64:
+			n, err := src.parseUint(bitSize64)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = n
+		default:
+			if nextDst, retry := pgtype.GetAssignToDstType(dst); retry {
+				return src.AssignTo(nextDst)
+			}
+
+			return fmt.Errorf("%w: %T", errAssignFailed, dst)
+		}
+	case src.Status == pgtype.Null:
+		if val, ok := dst.(*apd.NullDecimal); ok {
+			*val = apd.NullDecimal{}
+
+			return nil
+		}
+
+		if err := pgtype.NullAssignTo(dst); err != nil {
+			return fmt.Errorf("%w: %T", errAssignFailed, dst)
+		}
+
+		return nil
+	case src.Status == pgtype.Undefined:
+		return fmt.Errorf("AssignTo: %w", ErrUndefined)
+	}
+
+	return nil
+}
+
+// integer returns the decimal digits of src if it is an integer.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) integer() (string, error) {
+	if src.Decimal.Form != apd.Finite {
+		return "", errConversionFailed
+	}
+
+	var integ, frac apd.Decimal
+
+	src.Decimal.Modf(&integ, &frac)
+
+	if !frac.IsZero() {
+		return "", errConversionFailed
+	}
+
+	return integ.Text('f'), nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) parseInt(bitSize int) (int64, error) {
+	text, err := src.integer()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseInt(text, base, bitSize)
+	if err != nil {
+		return 0, errConversionFailed
+	}
+
+	return n, nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) parseUint(bitSize int) (uint64, error) {
+	text, err := src.integer()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseUint(text, base, bitSize)
+	if err != nil {
+		return 0, errConversionFailed
+	}
+
+	return n, nil
+}
+
+// DecodeText decodes numeric values, including NaN, Infinity and -Infinity.
+func (dst *Numeric) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	var dec apd.Decimal
+	if _, _, err := dec.SetString(string(src)); err != nil {
+		return fmt.Errorf("%w: %s", errConversionFailed, err.Error())
+	}
+
+	*dst = Numeric{Decimal: dec, Status: pgtype.Present}
+
+	return nil
+}
+
+// DecodeBinary decodes numeric values, including NaN, Infinity and -Infinity.
+func (dst *Numeric) DecodeBinary(connInfo *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	num, err := pgnumeric.DecodeBinary(src)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errConversionFailed, err.Error())
+	}
+
+	*dst = Numeric{Status: pgtype.Present}
+
+	switch {
+	case num.Form == pgnumeric.NaN:
+		dst.Decimal.Form = apd.NaN
+	case num.Form == pgnumeric.Infinity:
+		dst.Decimal.Form = apd.Infinite
+		dst.Decimal.Negative = num.Negative
+	default:
+		if num.Big != nil {
+			dst.Decimal.Coeff.SetMathBigInt(num.Big)
+		} else {
+			dst.Decimal.Coeff.SetUint64(num.Compact)
+		}
+
+		dst.Decimal.Negative = num.Negative
+		dst.Decimal.Exponent = int32(-num.Scale)
+	}
+
+	return nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch {
+	case src.Status == pgtype.Null:
+		return nil, nil
+	case src.Status == pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	// apd writes signaling NaNs as sNaN, Postgres only has NaN
+	if src.Decimal.Form == apd.NaN || src.Decimal.Form == apd.NaNSignaling {
+		return append(buf, "NaN"...), nil
+	}
+
+	return src.Decimal.Append(buf, 'G'), nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) EncodeBinary(connInfo *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch {
+	case src.Status == pgtype.Null:
+		return nil, nil
+	case src.Status == pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	num := pgnumeric.Number{Negative: src.Decimal.Negative}
+
+	switch src.Decimal.Form {
+	case apd.NaN, apd.NaNSignaling:
+		num.Form = pgnumeric.NaN
+	case apd.Infinite:
+		num.Form = pgnumeric.Infinity
+	case apd.Finite:
+		num.Scale = -int(src.Decimal.Exponent)
+
+		if src.Decimal.Coeff.IsUint64() {
+			num.Compact = src.Decimal.Coeff.Uint64()
+		} else {
+			num.Big = src.Decimal.Coeff.MathBigInt()
+		}
+	}
+
+	bytes, err := pgnumeric.AppendBinary(buf, num)
+	if err != nil {
+		return nil, fmt.Errorf("encode %v: %w", &src.Decimal, err)
+	}
+
+	return bytes, nil
+}
+
+// Scan implements the database/sql Scanner interface.
+func (dst *Numeric) Scan(src interface{}) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	switch src := src.(type) {
+	case float64:
+		dst.setFloat64(src)
+
+		return nil
+	case string:
+		return dst.DecodeText(nil, []byte(src))
+	case []byte:
+		return dst.DecodeText(nil, src)
+	}
+
+	return fmt.Errorf("%w %T", errScanFailed, src)
+}
+
+// Value implements the database/sql/driver Valuer interface.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) Value() (driver.Value, error) {
+	switch src.Status {
+	case pgtype.Present:
+		buf, err := src.EncodeText(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(buf), nil
+	case pgtype.Null:
+		return nil, nil
+	default:
+		return nil, ErrUndefined
+	}
+}
+
+// MarshalJSON writes NaN, Infinity and -Infinity as strings, like pgtype.Numeric,
+// since they are not valid JSON numbers.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) MarshalJSON() ([]byte, error) {
+	switch src.Status {
+	case pgtype.Present:
+		switch {
+		case src.Decimal.Form == apd.NaN || src.Decimal.Form == apd.NaNSignaling:
+			return []byte(`"NaN"`), nil
+		case src.Decimal.Form == apd.Infinite && src.Decimal.Negative:
+			return []byte(`"-Infinity"`), nil
+		case src.Decimal.Form == apd.Infinite:
+			return []byte(`"Infinity"`), nil
+		}
+
+		return src.Decimal.Append(nil, 'G'), nil
+	case pgtype.Null:
+		return []byte("null"), nil
+	case pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	return nil, ErrBadStatus
+}
+
+// UnmarshalJSON accepts JSON numbers and strings, such as "NaN".
+func (dst *Numeric) UnmarshalJSON(bytes []byte) error {
+	if string(bytes) == "null" {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	if len(bytes) >= 2 && bytes[0] == '"' && bytes[len(bytes)-1] == '"' {
+		bytes = bytes[1 : len(bytes)-1]
+	}
+
+	if err := dst.DecodeText(nil, bytes); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return nil
+}
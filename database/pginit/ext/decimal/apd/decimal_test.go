@@ -0,0 +1,81 @@
+package apd_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/apd/v3"
+	"github.com/jackc/pgtype"
+	pgapd "github.com/monacohq/golang-common/database/pginit/ext/decimal/apd"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/decimaltest"
+)
+
+func TestMain(m *testing.M) {
+	decimaltest.Main(m)
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	decimaltest.RunConformance(t, decimaltest.Suite{
+		New:           func() decimaltest.Numeric { return &pgapd.Numeric{} },
+		SpecialValues: true,
+	})
+}
+
+func TestNumericSetAssignToDecimal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		source   interface{}
+		expected string
+		status   pgtype.Status
+	}{
+		{source: *apd.New(15, -1), expected: "1.5", status: pgtype.Present},
+		{source: apd.New(15, -1), expected: "1.5", status: pgtype.Present},
+		{source: apd.NullDecimal{Decimal: *apd.New(-15, 3), Valid: true}, expected: "-1.5E+4", status: pgtype.Present},
+		{source: apd.NullDecimal{}, status: pgtype.Null},
+	}
+
+	for i, tt := range tests {
+		num := &pgapd.Numeric{}
+		if err := num.Set(tt.source); err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		if num.Status != tt.status || (tt.status == pgtype.Present && num.Decimal.String() != tt.expected) {
+			t.Errorf("%d: expected %v to convert to %s, but it was %v", i, tt.source, tt.expected, num)
+		}
+
+		var nullDecimal apd.NullDecimal
+		if err := num.AssignTo(&nullDecimal); err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		if nullDecimal.Valid != (tt.status == pgtype.Present) || (nullDecimal.Valid && nullDecimal.Decimal.String() != tt.expected) {
+			t.Errorf("%d: expected %v to assign %s, but it was %v", i, num, tt.expected, nullDecimal)
+		}
+	}
+}
+
+func TestNumericSignalingNaN(t *testing.T) {
+	t.Parallel()
+
+	num := &pgapd.Numeric{}
+	if err := num.Set("sNaN"); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	// Postgres only has quiet NaNs
+	if text, err := num.EncodeText(nil, nil); err != nil || string(text) != "NaN" {
+		t.Errorf("expected NaN but got %s, %v", text, err)
+	}
+
+	buf, err := num.EncodeBinary(nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	if err := num.DecodeBinary(nil, buf); err != nil || num.Decimal.Form != apd.NaN {
+		t.Errorf("expected NaN but got %v, %v", num.Decimal.Form, err)
+	}
+}
@@ -0,0 +1,76 @@
+package ericlagergren
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/pgnumeric"
+)
+
+const compactCoefficientLen = 8
+
+// appendNumericBinary appends the Postgres binary representation of dec to buf.
+// Infinity and -Infinity require Postgres 14.
+func appendNumericBinary(buf []byte, dec *decimal.Big) ([]byte, error) {
+	num := pgnumeric.Number{Negative: dec.Signbit()}
+
+	switch {
+	case dec.IsNaN(0):
+		num.Form = pgnumeric.NaN
+	case dec.IsInf(0):
+		num.Form = pgnumeric.Infinity
+	default:
+		var coeffBuf [compactCoefficientLen]byte
+
+		_, negative, coefficient, exponent := dec.Decompose(coeffBuf[:0])
+		num.Negative, num.Scale = negative, -int(exponent)
+
+		if len(coefficient) <= compactCoefficientLen {
+			for _, b := range coefficient {
+				num.Compact = num.Compact<<8 | uint64(b)
+			}
+		} else {
+			num.Big = new(big.Int).SetBytes(coefficient)
+		}
+	}
+
+	buf, err := pgnumeric.AppendBinary(buf, num)
+	if err != nil {
+		return nil, fmt.Errorf("encode %v: %w", dec, err)
+	}
+
+	return buf, nil
+}
+
+// decodeNumericBinary sets dec to the numeric in Postgres binary representation src.
+func decodeNumericBinary(dec *decimal.Big, src []byte) error {
+	num, err := pgnumeric.DecodeBinary(src)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errConversionFailed, err.Error())
+	}
+
+	switch {
+	case num.Form == pgnumeric.NaN:
+		dec.SetNaN(false)
+	case num.Form == pgnumeric.Infinity:
+		dec.SetInf(num.Negative)
+	case num.Big == nil && num.Compact <= math.MaxInt64:
+		dec.SetMantScale(int64(num.Compact), num.Scale)
+		dec.SetSignbit(num.Negative)
+	default:
+		mant := num.Big
+		if mant == nil {
+			mant = new(big.Int).SetUint64(num.Compact)
+		}
+
+		if num.Negative {
+			mant.Neg(mant)
+		}
+
+		dec.SetBigMantScale(mant, num.Scale)
+	}
+
+	return nil
+}
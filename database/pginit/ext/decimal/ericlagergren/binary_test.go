@@ -0,0 +1,186 @@
+package ericlagergren_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+)
+
+// binaryBenchmarks are typical amounts, from a price to values exceeding an uint64.
+var binaryBenchmarks = []struct { // nolint: gochecknoglobals, nolintlint
+	name      string
+	numberStr string
+}{
+	{"Zero", "0"},
+	{"Small", "12345"},
+	{"Amount", "1234.56"},
+	{"Medium", "12345.12345"},
+	{"Large", "123457890.1234567890"},
+	{"Huge", "123457890123457890123457890.1234567890123457890123457890"},
+}
+
+// pgtypeEncodeBinary is the former EncodeBinary, going through text and pgtype.Numeric.
+func pgtypeEncodeBinary(src *ericlagergren.Numeric, buf []byte) ([]byte, error) {
+	num := &pgtype.Numeric{}
+	if err := num.DecodeText(nil, []byte(fmt.Sprintf("%f", &src.Decimal))); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	bytes, err := num.EncodeBinary(nil, buf)
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	return bytes, nil
+}
+
+// pgtypeDecodeBinary is the former DecodeBinary, going through pgtype.Numeric.
+func pgtypeDecodeBinary(dst *ericlagergren.Numeric, src []byte) error {
+	num := &pgtype.Numeric{}
+	if err := num.DecodeBinary(nil, src); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	*dst = ericlagergren.Numeric{Decimal: *new(decimal.Big).SetBigMantScale(num.Int, -int(num.Exp)), Status: pgtype.Present}
+
+	return nil
+}
+
+func BenchmarkEncodeBinary(b *testing.B) {
+	for _, bm := range binaryBenchmarks {
+		src := &ericlagergren.Numeric{}
+		if err := src.Set(bm.numberStr); err != nil {
+			b.Errorf("expected no error but got %v", err)
+		}
+
+		buf := make([]byte, 0, 128)
+
+		b.Run(fmt.Sprintf("%s-Native", bm.name), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := src.EncodeBinary(nil, buf[:0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%s-Pgtype", bm.name), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := pgtypeEncodeBinary(src, buf[:0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	for _, bm := range binaryBenchmarks {
+		src := &ericlagergren.Numeric{}
+		if err := src.Set(bm.numberStr); err != nil {
+			b.Errorf("expected no error but got %v", err)
+		}
+
+		binaryFormat, err := src.EncodeBinary(nil, nil)
+		if err != nil {
+			b.Errorf("expected no error but got %v", err)
+		}
+
+		b.Run(fmt.Sprintf("%s-Native", bm.name), func(b *testing.B) {
+			b.ReportAllocs()
+
+			dst := &ericlagergren.Numeric{}
+			for i := 0; i < b.N; i++ {
+				if err := dst.DecodeBinary(nil, binaryFormat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%s-Pgtype", bm.name), func(b *testing.B) {
+			b.ReportAllocs()
+
+			dst := &ericlagergren.Numeric{}
+			for i := 0; i < b.N; i++ {
+				if err := pgtypeDecodeBinary(dst, binaryFormat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func FuzzNumericBinaryRoundTrip(f *testing.F) {
+	for _, bm := range binaryBenchmarks {
+		f.Add(bm.numberStr)
+	}
+
+	for _, seed := range []string{"-1", "1E+5", "1.50", "0.0001", "-0.00000123", "1E-20", "99999999999999999999", "0E-13"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, numberStr string) {
+		dec, ok := new(decimal.Big).SetString(numberStr)
+		// the scale is limited by the numeric type
+		if !ok || !dec.IsFinite() || dec.Scale() > 1000 || dec.Scale() < -1000 {
+			t.Skip()
+		}
+
+		src := &ericlagergren.Numeric{Decimal: *dec, Status: pgtype.Present}
+
+		buf, err := src.EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatalf("expected no error encoding %s but got %v", numberStr, err)
+		}
+
+		dst := &ericlagergren.Numeric{}
+		if err := dst.DecodeBinary(nil, buf); err != nil {
+			t.Fatalf("expected no error decoding %s but got %v", numberStr, err)
+		}
+
+		if dst.Decimal.Cmp(dec) != 0 {
+			t.Fatalf("expected %s but got %s", dec, &dst.Decimal)
+		}
+
+		// both implementations must agree on the wire format
+		legacy := &ericlagergren.Numeric{}
+		if err := pgtypeDecodeBinary(legacy, buf); err != nil {
+			t.Fatalf("expected no error decoding %s with pgtype but got %v", numberStr, err)
+		}
+
+		if legacy.Decimal.String() != dst.Decimal.String() {
+			t.Fatalf("expected %s as decoded by pgtype but got %s", &legacy.Decimal, &dst.Decimal)
+		}
+
+		legacyBuf, err := pgtypeEncodeBinary(src, nil)
+		if err != nil {
+			t.Fatalf("expected no error encoding %s with pgtype but got %v", numberStr, err)
+		}
+
+		if err := dst.DecodeBinary(nil, legacyBuf); err != nil {
+			t.Fatalf("expected no error decoding %s but got %v", numberStr, err)
+		}
+
+		if dst.Decimal.Cmp(dec) != 0 {
+			t.Fatalf("expected %s but got %s", dec, &dst.Decimal)
+		}
+	})
+}
+
+func FuzzNumericDecodeBinary(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0, 1, 0, 0, 0x40, 0, 0, 2, 0, 1})
+	f.Add([]byte{0, 2, 255, 255, 0, 0, 0, 8, 0, 1, 0, 2})
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		// malformed input must be an error, not a panic
+		dst := &ericlagergren.Numeric{}
+		_ = dst.DecodeBinary(nil, src)
+	})
+}
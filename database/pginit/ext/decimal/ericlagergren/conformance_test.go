@@ -0,0 +1,17 @@
+package ericlagergren_test
+
+import (
+	"testing"
+
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/decimaltest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	decimaltest.RunConformance(t, decimaltest.Suite{
+		New:           func() decimaltest.Numeric { return &ericlagergren.Numeric{} },
+		SpecialValues: true,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 
 	"github.com/ericlagergren/decimal"
@@ -29,10 +30,30 @@ const (
 type Numeric struct {
 	Decimal decimal.Big
 	Status  pgtype.Status
+
+	// MarshalMode selects how the value is written by MarshalJSON, MarshalText and String,
+	// it is kept when scanning or unmarshalling into the Numeric.
+	MarshalMode MarshalMode
+
+	// rounding is applied to scanned values, see NewNumeric.
+	rounding *rounding
 }
 
-// nolint: cyclop // need to check each type to make sure all case is covered
+// Set assigns src to the Numeric, MarshalMode and the rounding options are kept.
 func (dst *Numeric) Set(src interface{}) error {
+	mode, rounding := dst.MarshalMode, dst.rounding
+
+	if err := dst.set(src); err != nil {
+		return err
+	}
+
+	dst.MarshalMode, dst.rounding = mode, rounding
+
+	return nil
+}
+
+// nolint: cyclop // need to check each type to make sure all case is covered
+func (dst *Numeric) set(src interface{}) error {
 	if src == nil {
 		*dst = Numeric{Status: pgtype.Null}
 
@@ -134,11 +155,9 @@ func (src *Numeric) AssignTo(dst interface{}) error {
 		case *decimal.Big:
 			*val = src.Decimal
 		case *float32:
-			f, _ := src.Decimal.Float64()
-			*val = float32(f)
+			*val = float32(src.float64())
 		case *float64:
-			f, _ := src.Decimal.Float64()
-			*val = f
+			*val = src.float64()
 		case *int:
 			if src.Decimal.Scale() < 0 {
 				return fmt.Errorf("%w: %v to %T", errConversionFailed, dst, *val)
@@ -269,9 +288,10 @@ func (src *Numeric) AssignTo(dst interface{}) error {
 	return nil
 }
 
+// DecodeText decodes numeric values, including NaN, Infinity and -Infinity.
 func (dst *Numeric) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
 	if src == nil {
-		*dst = Numeric{Status: pgtype.Null}
+		*dst = Numeric{Status: pgtype.Null, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 		return nil
 	}
@@ -281,26 +301,33 @@ func (dst *Numeric) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
 		return fmt.Errorf("set: %w", errConversionFailed)
 	}
 
-	*dst = Numeric{Decimal: *dec, Status: pgtype.Present}
+	if err := dst.rounding.round(dec); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	*dst = Numeric{Decimal: *dec, Status: pgtype.Present, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 	return nil
 }
 
+// DecodeBinary decodes numeric values, including NaN, Infinity and -Infinity.
 func (dst *Numeric) DecodeBinary(connInfo *pgtype.ConnInfo, src []byte) error {
 	if src == nil {
-		*dst = Numeric{Status: pgtype.Null}
+		*dst = Numeric{Status: pgtype.Null, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 		return nil
 	}
 
-	// For now at least, implement this in terms of pgtype.Numeric
+	var dec decimal.Big
+	if err := decodeNumericBinary(&dec, src); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
 
-	num := &pgtype.Numeric{}
-	if err := num.DecodeBinary(connInfo, src); err != nil {
+	if err := dst.rounding.round(&dec); err != nil {
 		return fmt.Errorf("decode: %w", err)
 	}
 
-	*dst = Numeric{Decimal: *new(decimal.Big).SetBigMantScale(num.Int, -int(num.Exp)), Status: pgtype.Present}
+	*dst = Numeric{Decimal: dec, Status: pgtype.Present, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 	return nil
 }
@@ -314,6 +341,11 @@ func (src *Numeric) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error)
 		return nil, ErrUndefined
 	}
 
+	// decimal writes signaling NaNs as sNaN, Postgres only has NaN
+	if src.Decimal.IsNaN(0) {
+		return append(buf, "NaN"...), nil
+	}
+
 	return append(buf, src.Decimal.String()...), nil
 }
 
@@ -326,13 +358,7 @@ func (src *Numeric) EncodeBinary(connInfo *pgtype.ConnInfo, buf []byte) ([]byte,
 		return nil, ErrUndefined
 	}
 
-	// For now at least, implement this in terms of pgtype.Numeric
-	num := &pgtype.Numeric{}
-	if err := num.DecodeText(connInfo, []byte(fmt.Sprintf("%f", &src.Decimal))); err != nil {
-		return nil, fmt.Errorf("decode: %w", err)
-	}
-
-	bytes, err := num.EncodeBinary(connInfo, buf)
+	bytes, err := appendNumericBinary(buf, &src.Decimal)
 	if err != nil {
 		return nil, fmt.Errorf("encode: %w", err)
 	}
@@ -343,14 +369,19 @@ func (src *Numeric) EncodeBinary(connInfo *pgtype.ConnInfo, buf []byte) ([]byte,
 // Scan implements the database/sql Scanner interface.
 func (dst *Numeric) Scan(src interface{}) error {
 	if src == nil {
-		*dst = Numeric{Status: pgtype.Null}
+		*dst = Numeric{Status: pgtype.Null, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 		return nil
 	}
 
 	switch src := src.(type) {
 	case float64:
-		*dst = Numeric{Decimal: *new(decimal.Big).SetFloat64(src), Status: pgtype.Present}
+		dec := new(decimal.Big).SetFloat64(src)
+		if err := dst.rounding.round(dec); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+
+		*dst = Numeric{Decimal: *dec, Status: pgtype.Present, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
 
 		return nil
 	case string:
@@ -366,6 +397,8 @@ func (dst *Numeric) Scan(src interface{}) error {
 // nolint: revive // different naming is to diffrentiate source and destination
 func (src *Numeric) Value() (driver.Value, error) {
 	switch {
+	case src.Status == pgtype.Present && src.Decimal.IsNaN(0):
+		return "NaN", nil
 	case src.Status == pgtype.Present:
 		return src.Decimal.String(), nil
 	case src.Status == pgtype.Null:
@@ -376,37 +409,13 @@ func (src *Numeric) Value() (driver.Value, error) {
 }
 
 // nolint: revive // different naming is to diffrentiate source and destination
-func (src *Numeric) MarshalJSON() ([]byte, error) {
-	switch src.Status {
-	case pgtype.Present:
-		bytes, err := src.Decimal.MarshalText()
-		if err != nil {
-			return nil, fmt.Errorf("marshal: %w", err)
-		}
-
-		return bytes, nil
-	case pgtype.Null:
-		return []byte("null"), nil
-	case pgtype.Undefined:
-		return nil, ErrUndefined
+func (src *Numeric) float64() float64 {
+	// decimal returns +Inf for -Infinity
+	if src.Decimal.IsInf(-1) {
+		return math.Inf(-1)
 	}
 
-	return nil, ErrBadStatus
-}
-
-func (dst *Numeric) UnmarshalJSON(bytes []byte) error {
-	dec := new(decimal.Big)
-
-	if err := dec.UnmarshalJSON(bytes); err != nil {
-		return fmt.Errorf("unmarshal: %w", err)
-	}
+	f, _ := src.Decimal.Float64()
 
-	status := pgtype.Null
-	if string(bytes) != "null" {
-		status = pgtype.Present
-	}
-
-	*dst = Numeric{Decimal: *dec, Status: status}
-
-	return nil
+	return f
 }
@@ -2,116 +2,22 @@ package ericlagergren_test
 
 import (
 	"bytes"
-	"context"
 	"errors"
-	"flag"
 	"fmt"
-	"log"
+	"math"
 	"math/big"
 	"math/rand"
-	"net/url"
-	"os"
 	"reflect"
-	"strings"
 	"testing"
-	"time"
 
 	"github.com/ericlagergren/decimal"
 	"github.com/jackc/pgtype"
-	"github.com/jackc/pgx/v4"
 	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
-	"github.com/ory/dockertest/v3"
-	"github.com/ory/dockertest/v3/docker"
-	"go.uber.org/goleak"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/decimaltest"
 )
 
 func TestMain(m *testing.M) {
-	leak := flag.Bool("leak", false, "use leak detector")
-	flag.Parse()
-
-	if *leak {
-		goleak.VerifyTestMain(m)
-
-		return
-	}
-
-	// uses a sensible default on windows (tcp/http) and linux/osx (socket)
-	pool, err := dockertest.NewPool("")
-	if err != nil {
-		log.Fatalf("Could not connect to docker: %s", err)
-	}
-
-	// pulls an image, creates a container based on it and runs it
-	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
-		Repository: "postgres",
-		Tag:        "14",
-		Env: []string{
-			"POSTGRES_PASSWORD=postgres",
-			"POSTGRES_USER=postgres",
-			"POSTGRES_DB=datawarehouse",
-			"listen_addresses = '*'",
-		},
-	}, func(config *docker.HostConfig) {
-		// set AutoRemove to true so that stopped container goes away by itself
-		config.AutoRemove = true
-		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
-	})
-	if err != nil {
-		log.Fatalf("Could not start resource: %s", err)
-	}
-
-	databaseURL := fmt.Sprintf("postgres://postgres:%s@%s/datawarehouse?sslmode=disable", "postgres", getHostPort(resource, "5432/tcp"))
-	resource.Expire(180) // Tell docker to hard kill the container in 180 seconds
-	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
-	pool.MaxWait = 120 * time.Second
-	if err := pool.Retry(func() error {
-		ctx := context.Background()
-		db, err := pgx.Connect(ctx, databaseURL)
-		if err != nil {
-			return fmt.Errorf("pgx connect: %w", err)
-		}
-		if err := db.Ping(ctx); err != nil {
-			return fmt.Errorf("ping: %w", err)
-		}
-		os.Setenv("PGX_TEST_DATABASE", databaseURL)
-
-		return nil
-	}); err != nil {
-		log.Fatalf("Could not connect to docker(%s): %s", databaseURL, err)
-	}
-
-	code := m.Run()
-
-	// You can't defer this because os.Exit doesn't care for defer
-	if err := pool.Purge(resource); err != nil {
-		log.Fatalf("Could not purge resource: %s", err)
-	}
-
-	os.Exit(code)
-}
-
-func getHostPort(resource *dockertest.Resource, id string) string {
-	var testHost, testPort string
-
-	dockerURL := os.Getenv("DOCKER_HOST")
-	if dockerURL == "" {
-		hostAndPort := resource.GetHostPort("5432/tcp")
-		hp := strings.Split(hostAndPort, ":")
-		testHost = hp[0]
-		testPort = hp[1]
-
-		return testHost + ":" + testPort
-	}
-
-	u, err := url.Parse(dockerURL)
-	if err != nil {
-		panic(err)
-	}
-
-	testHost = u.Hostname()
-	testPort = resource.GetPort(id)
-
-	return testHost + ":" + testPort
+	decimaltest.Main(m)
 }
 
 func mustParseDecimal(t *testing.T, src string) decimal.Big {
@@ -128,7 +34,7 @@ func mustParseDecimal(t *testing.T, src string) decimal.Big {
 func TestNumericNormalize(t *testing.T) {
 	t.Parallel()
 
-	SuccessfulNormalizeEqFunc(t, []NormalizeTest{
+	decimaltest.SuccessfulNormalizeEqFunc(t, []decimaltest.NormalizeTest{
 		{
 			SQL:   "select '0'::numeric",
 			Value: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "0"), Status: pgtype.Present},
@@ -212,7 +118,7 @@ func TestNumericNormalize(t *testing.T) {
 func TestNumericTranscode(t *testing.T) {
 	t.Parallel()
 
-	SuccessfulTranscodeEqFunc(t, "numeric", []interface{}{
+	decimaltest.SuccessfulTranscodeEqFunc(t, "numeric", []interface{}{
 		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "0"), Status: pgtype.Present},
 		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "1"), Status: pgtype.Present},
 		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "-1"), Status: pgtype.Present},
@@ -252,6 +158,27 @@ func TestNumericTranscode(t *testing.T) {
 	})
 }
 
+func TestNumericTranscodeSpecialValues(t *testing.T) {
+	t.Parallel()
+
+	decimaltest.SuccessfulTranscodeEqFunc(t, "numeric", []interface{}{
+		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "NaN"), Status: pgtype.Present},
+		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "Infinity"), Status: pgtype.Present},
+		&ericlagergren.Numeric{Decimal: mustParseDecimal(t, "-Infinity"), Status: pgtype.Present},
+	}, func(aa, bb interface{}) bool {
+		a, ok := aa.(ericlagergren.Numeric)
+		if !ok {
+			t.Errorf("cannot convert %T", aa)
+		}
+		b, ok := bb.(ericlagergren.Numeric)
+		if !ok {
+			t.Errorf("cannot convert %T", bb)
+		}
+
+		return a.Status == b.Status && a.Decimal.String() == b.Decimal.String()
+	})
+}
+
 func TestNumericTranscodeFuzz(t *testing.T) {
 	t.Parallel()
 
@@ -272,7 +199,7 @@ func TestNumericTranscodeFuzz(t *testing.T) {
 		)
 	}
 
-	SuccessfulTranscodeEqFunc(t, "numeric", values,
+	decimaltest.SuccessfulTranscodeEqFunc(t, "numeric", values,
 		func(aa, bb interface{}) bool {
 			a, ok := aa.(ericlagergren.Numeric)
 			if !ok {
@@ -376,6 +303,9 @@ func TestNumericAssignTo(t *testing.T) {
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "42"), Status: pgtype.Present}, dst: &f64, expected: float64(42)},
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "4.2"), Status: pgtype.Present}, dst: &f32, expected: float32(4.2)},
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "4.2"), Status: pgtype.Present}, dst: &f64, expected: float64(4.2)},
+		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "Infinity"), Status: pgtype.Present}, dst: &f64, expected: math.Inf(1)},
+		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "-Infinity"), Status: pgtype.Present}, dst: &f64, expected: math.Inf(-1)},
+		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "-Infinity"), Status: pgtype.Present}, dst: &f32, expected: float32(math.Inf(-1))},
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "42"), Status: pgtype.Present}, dst: &i16, expected: int16(42)},
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "42"), Status: pgtype.Present}, dst: &i32, expected: int32(42)},
 		{src: &ericlagergren.Numeric{Decimal: mustParseDecimal(t, "42"), Status: pgtype.Present}, dst: &i64, expected: int64(42)},
@@ -556,6 +486,22 @@ func TestNumericMarshalJSON(t *testing.T) {
 			expected: []byte("0.00123"),
 			err:      nil,
 		},
+		{
+			src: &ericlagergren.Numeric{
+				Decimal: mustParseDecimal(t, "NaN"),
+				Status:  pgtype.Present,
+			},
+			expected: []byte(`"NaN"`),
+			err:      nil,
+		},
+		{
+			src: &ericlagergren.Numeric{
+				Decimal: mustParseDecimal(t, "-Infinity"),
+				Status:  pgtype.Present,
+			},
+			expected: []byte(`"-Infinity"`),
+			err:      nil,
+		},
 		{
 			src: &ericlagergren.Numeric{
 				Decimal: *decimal.New(123, 5),
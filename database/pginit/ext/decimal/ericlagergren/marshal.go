@@ -0,0 +1,181 @@
+package ericlagergren
+
+import (
+	"fmt"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+)
+
+type marshalKind uint8
+
+const (
+	marshalNumber marshalKind = iota
+	marshalString
+	marshalFixed
+)
+
+// MarshalMode selects how MarshalJSON, MarshalText and String write a Numeric,
+// the zero MarshalMode is MarshalNumber.
+type MarshalMode struct {
+	kind  marshalKind
+	scale int
+}
+
+// MarshalNumber writes JSON numbers, in exponent notation when shorter, e.g. 1.23E+5.
+func MarshalNumber() MarshalMode {
+	return MarshalMode{kind: marshalNumber}
+}
+
+// MarshalString writes JSON strings in plain notation, e.g. "123000", so that
+// JavaScript clients do not lose precision.
+func MarshalString() MarshalMode {
+	return MarshalMode{kind: marshalString}
+}
+
+// MarshalFixed writes JSON strings in plain notation with scale digits after the decimal point,
+// rounded half away from zero, e.g. "10.50" for money amounts with scale 2.
+func MarshalFixed(scale int) MarshalMode {
+	if scale < 0 {
+		scale = 0
+	}
+
+	return MarshalMode{kind: marshalFixed, scale: scale}
+}
+
+// appendText appends the text of a finite or special dec according to mode.
+func (mode MarshalMode) appendText(buf []byte, dec *decimal.Big) []byte {
+	switch {
+	case dec.IsNaN(0):
+		return append(buf, "NaN"...)
+	case dec.IsInf(1):
+		return append(buf, "Infinity"...)
+	case dec.IsInf(-1):
+		return append(buf, "-Infinity"...)
+	}
+
+	switch mode.kind {
+	case marshalString:
+		return append(buf, fmt.Sprintf("%f", dec)...)
+	case marshalFixed:
+		return append(buf, fmt.Sprintf("%f", mode.quantize(dec))...)
+	default:
+		return append(buf, dec.String()...)
+	}
+}
+
+// quantize returns a copy of dec with mode.scale digits after the decimal point.
+func (mode MarshalMode) quantize(dec *decimal.Big) *decimal.Big {
+	fixed := new(decimal.Big).Copy(dec)
+	fixed.Context = decimal.Context{RoundingMode: decimal.ToNearestAway}
+
+	// enough precision for the integer part and the scale, so that Quantize does not fail
+	if precision := dec.Precision() - dec.Scale() + mode.scale + 1; precision > 0 {
+		fixed.Context.Precision = precision
+	}
+
+	fixed.Quantize(mode.scale)
+
+	// amounts rounded to zero are written without sign
+	if fixed.Sign() == 0 {
+		fixed.SetSignbit(false)
+	}
+
+	return fixed
+}
+
+// quoted reports whether the JSON of dec is a string.
+func (mode MarshalMode) quoted(dec *decimal.Big) bool {
+	return mode.kind != marshalNumber || !dec.IsFinite()
+}
+
+// MarshalJSON writes the decimal according to MarshalMode, NaN, Infinity and -Infinity
+// are always strings, like pgtype.Numeric, since they are not valid JSON numbers.
+// It has a value receiver so that response structs holding a Numeric by value are marshalled too.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src Numeric) MarshalJSON() ([]byte, error) {
+	switch src.Status {
+	case pgtype.Present:
+		if !src.MarshalMode.quoted(&src.Decimal) {
+			return src.MarshalMode.appendText(nil, &src.Decimal), nil
+		}
+
+		buf := append([]byte{}, '"')
+		buf = src.MarshalMode.appendText(buf, &src.Decimal)
+
+		return append(buf, '"'), nil
+	case pgtype.Null:
+		return []byte("null"), nil
+	case pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	return nil, ErrBadStatus
+}
+
+// UnmarshalJSON accepts JSON numbers and strings, MarshalMode and the rounding options are kept.
+func (dst *Numeric) UnmarshalJSON(bytes []byte) error {
+	dec := new(decimal.Big)
+
+	if err := dec.UnmarshalJSON(bytes); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	status := pgtype.Null
+	if string(bytes) != "null" {
+		status = pgtype.Present
+	}
+
+	*dst = Numeric{Decimal: *dec, Status: status, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
+
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler according to MarshalMode,
+// null is written as an empty text.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src Numeric) MarshalText() ([]byte, error) {
+	switch src.Status {
+	case pgtype.Present:
+		return src.MarshalMode.appendText(nil, &src.Decimal), nil
+	case pgtype.Null:
+		return []byte{}, nil
+	case pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	return nil, ErrBadStatus
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, an empty text is null,
+// MarshalMode and the rounding options are kept.
+func (dst *Numeric) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*dst = Numeric{Status: pgtype.Null, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
+
+		return nil
+	}
+
+	dec, ok := new(decimal.Big).SetString(string(text))
+	if !ok {
+		return fmt.Errorf("unmarshal: %w", errConversionFailed)
+	}
+
+	*dst = Numeric{Decimal: *dec, Status: pgtype.Present, MarshalMode: dst.MarshalMode, rounding: dst.rounding}
+
+	return nil
+}
+
+// String implements fmt.Stringer according to MarshalMode, so that a Numeric can be logged
+// with zerolog's Stringer, its Interface uses MarshalJSON.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src Numeric) String() string {
+	switch src.Status {
+	case pgtype.Present:
+		return string(src.MarshalMode.appendText(nil, &src.Decimal))
+	case pgtype.Null:
+		return "null"
+	default:
+		return "undefined"
+	}
+}
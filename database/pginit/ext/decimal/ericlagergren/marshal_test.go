@@ -0,0 +1,213 @@
+package ericlagergren_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+	"github.com/rs/zerolog"
+)
+
+func TestNumeric_MarshalMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		mode         ericlagergren.MarshalMode
+		src          string
+		expectedJSON string
+		expectedText string
+	}{
+		{
+			name:         "number by default",
+			src:          "1.23E+5",
+			expectedJSON: `1.23E+5`,
+			expectedText: "1.23E+5",
+		},
+		{
+			name:         "number",
+			mode:         ericlagergren.MarshalNumber(),
+			src:          "10.5",
+			expectedJSON: `10.5`,
+			expectedText: "10.5",
+		},
+		{
+			name:         "string in plain notation",
+			mode:         ericlagergren.MarshalString(),
+			src:          "1.23E+5",
+			expectedJSON: `"123000"`,
+			expectedText: "123000",
+		},
+		{
+			name:         "string of a small value",
+			mode:         ericlagergren.MarshalString(),
+			src:          "1E-7",
+			expectedJSON: `"0.0000001"`,
+			expectedText: "0.0000001",
+		},
+		{
+			name:         "fixed pads the scale",
+			mode:         ericlagergren.MarshalFixed(2),
+			src:          "10.5",
+			expectedJSON: `"10.50"`,
+			expectedText: "10.50",
+		},
+		{
+			name:         "fixed rounds half away from zero",
+			mode:         ericlagergren.MarshalFixed(2),
+			src:          "-1.005",
+			expectedJSON: `"-1.01"`,
+			expectedText: "-1.01",
+		},
+		{
+			name:         "fixed of a large value",
+			mode:         ericlagergren.MarshalFixed(2),
+			src:          "1E+30",
+			expectedJSON: `"1000000000000000000000000000000.00"`,
+			expectedText: "1000000000000000000000000000000.00",
+		},
+		{
+			name:         "fixed without sign for zero",
+			mode:         ericlagergren.MarshalFixed(2),
+			src:          "-0.001",
+			expectedJSON: `"0.00"`,
+			expectedText: "0.00",
+		},
+		{
+			name:         "fixed with negative scale",
+			mode:         ericlagergren.MarshalFixed(-1),
+			src:          "2.5",
+			expectedJSON: `"3"`,
+			expectedText: "3",
+		},
+		{
+			name:         "special values stay strings",
+			mode:         ericlagergren.MarshalFixed(2),
+			src:          "-Infinity",
+			expectedJSON: `"-Infinity"`,
+			expectedText: "-Infinity",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, ok := new(decimal.Big).SetString(tt.src)
+			if !ok {
+				t.Fatalf("invalid decimal %s", tt.src)
+			}
+
+			num := &ericlagergren.Numeric{Decimal: *dec, Status: pgtype.Present, MarshalMode: tt.mode}
+
+			bytes, err := json.Marshal(num)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			if string(bytes) != tt.expectedJSON {
+				t.Errorf("expected JSON %s but got %s", tt.expectedJSON, bytes)
+			}
+
+			text, err := num.MarshalText()
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			if string(text) != tt.expectedText {
+				t.Errorf("expected text %s but got %s", tt.expectedText, text)
+			}
+
+			if num.String() != tt.expectedText {
+				t.Errorf("expected string %s but got %s", tt.expectedText, num.String())
+			}
+		})
+	}
+}
+
+func TestNumeric_UnmarshalKeepsMarshalMode(t *testing.T) {
+	t.Parallel()
+
+	var resp struct {
+		Amount ericlagergren.Numeric `json:"amount"`
+	}
+
+	resp.Amount.MarshalMode = ericlagergren.MarshalFixed(2)
+
+	if err := json.Unmarshal([]byte(`{"amount":"12.3"}`), &resp); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if expected := `{"amount":"12.30"}`; string(bytes) != expected {
+		t.Errorf("expected %s but got %s", expected, bytes)
+	}
+
+	if err := resp.Amount.DecodeText(nil, []byte("7")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if expected := "7.00"; resp.Amount.String() != expected {
+		t.Errorf("expected %s but got %s", expected, resp.Amount.String())
+	}
+}
+
+func TestNumeric_Text(t *testing.T) {
+	t.Parallel()
+
+	var num ericlagergren.Numeric
+
+	if err := num.UnmarshalText([]byte("1.50")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if num.Status != pgtype.Present || num.Decimal.String() != "1.50" {
+		t.Errorf("expected 1.50 but got %v %s", num.Status, num.Decimal.String())
+	}
+
+	if err := num.UnmarshalText(nil); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if num.Status != pgtype.Null {
+		t.Errorf("expected null but got %v", num.Status)
+	}
+
+	text, err := num.MarshalText()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if len(text) != 0 {
+		t.Errorf("expected empty text but got %s", text)
+	}
+
+	num = ericlagergren.Numeric{Status: pgtype.Undefined}
+	if _, err := num.MarshalText(); err == nil {
+		t.Error("expected error for undefined status")
+	}
+}
+
+func TestNumeric_Zerolog(t *testing.T) {
+	t.Parallel()
+
+	dec, _ := new(decimal.Big).SetString("1.5")
+	num := &ericlagergren.Numeric{Decimal: *dec, Status: pgtype.Present, MarshalMode: ericlagergren.MarshalFixed(2)}
+
+	var buf bytes.Buffer
+
+	logger := zerolog.New(&buf)
+	logger.Info().Stringer("amount", num).Interface("json", num).Msg("")
+
+	if expected := `{"level":"info","amount":"1.50","json":"1.50"}` + "\n"; buf.String() != expected {
+		t.Errorf("expected %s but got %s", expected, buf.String())
+	}
+}
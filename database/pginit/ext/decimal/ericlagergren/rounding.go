@@ -0,0 +1,93 @@
+package ericlagergren
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+)
+
+// ErrOutOfRange is returned when a scanned value does not fit the precision set by WithTypmod.
+var ErrOutOfRange = errors.New("numeric out of range")
+
+// NumericOption configures the rounding applied to the values scanned by a Numeric created by NewNumeric.
+type NumericOption func(*rounding)
+
+type rounding struct {
+	ctx      decimal.Context
+	scale    int
+	hasScale bool
+}
+
+// WithContext set the context of the scanned decimals, e.g. decimal.Context128,
+// and round them to its precision with its rounding mode.
+// Options given after it, such as WithTypmod, override its precision or rounding mode.
+func WithContext(ctx decimal.Context) NumericOption {
+	return func(r *rounding) {
+		r.ctx = ctx
+	}
+}
+
+// WithRoundingMode set the rounding mode used to round the scanned decimals, e.g. decimal.ToNearestEven
+// for banker's rounding. Postgres itself rounds half away from zero, decimal.ToNearestAway.
+func WithRoundingMode(mode decimal.RoundingMode) NumericOption {
+	return func(r *rounding) {
+		r.ctx.RoundingMode = mode
+	}
+}
+
+// WithTypmod rounds the scanned decimals to scale digits after the decimal point, like a
+// numeric(precision, scale) column, and fails with ErrOutOfRange if they need more than
+// precision digits.
+func WithTypmod(precision, scale int) NumericOption {
+	return func(r *rounding) {
+		r.ctx.Precision = precision
+		r.scale = scale
+		r.hasScale = true
+	}
+}
+
+// NewNumeric returns a Numeric rounding the values it scans according to opts,
+// to register as a pgtype.DataType in place of &Numeric{}.
+func NewNumeric(opts ...NumericOption) *Numeric {
+	r := &rounding{}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return &Numeric{rounding: r}
+}
+
+// NewTypeValue implements pgtype.TypeValue so that the rounding options are kept
+// by the copies registered to each connection.
+func (src *Numeric) NewTypeValue() pgtype.Value { // nolint: revive // different naming is to diffrentiate source and destination
+	return &Numeric{rounding: src.rounding}
+}
+
+// TypeName implements pgtype.TypeValue.
+func (src *Numeric) TypeName() string { // nolint: revive // different naming is to diffrentiate source and destination
+	return "numeric"
+}
+
+func (r *rounding) round(dec *decimal.Big) error {
+	if r == nil || !dec.IsFinite() || (!r.hasScale && r.ctx.Precision == 0) {
+		return nil
+	}
+
+	dec.Context = r.ctx
+
+	if r.hasScale {
+		dec.Quantize(r.scale)
+	} else {
+		dec.Context.Round(dec)
+	}
+
+	// decimal sets the result to NaN when it needs more digits than the context precision
+	if dec.IsNaN(0) {
+		return fmt.Errorf("%w: precision %d scale %d", ErrOutOfRange, r.ctx.Precision, r.scale)
+	}
+
+	return nil
+}
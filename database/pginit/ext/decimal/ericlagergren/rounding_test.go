@@ -0,0 +1,180 @@
+package ericlagergren_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
+)
+
+func TestNewNumeric_Rounding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		opts      []ericlagergren.NumericOption
+		src       string
+		expected  string
+		expectErr error
+	}{
+		{
+			name:     "no option keeps the value",
+			src:      "1.2345",
+			expected: "1.2345",
+		},
+		{
+			name:     "typmod rounds to the scale with banker's rounding by default",
+			opts:     []ericlagergren.NumericOption{ericlagergren.WithTypmod(10, 2)},
+			src:      "1.225",
+			expected: "1.22",
+		},
+		{
+			name: "typmod with rounding half away from zero like postgres",
+			opts: []ericlagergren.NumericOption{
+				ericlagergren.WithTypmod(10, 2),
+				ericlagergren.WithRoundingMode(decimal.ToNearestAway),
+			},
+			src:      "1.225",
+			expected: "1.23",
+		},
+		{
+			name:     "typmod pads the scale",
+			opts:     []ericlagergren.NumericOption{ericlagergren.WithTypmod(10, 4)},
+			src:      "1.5",
+			expected: "1.5000",
+		},
+		{
+			name:      "value exceeding the typmod precision",
+			opts:      []ericlagergren.NumericOption{ericlagergren.WithTypmod(4, 2)},
+			src:       "123.45",
+			expectErr: ericlagergren.ErrOutOfRange,
+		},
+		{
+			name:     "context precision",
+			opts:     []ericlagergren.NumericOption{ericlagergren.WithContext(decimal.Context32)},
+			src:      "1.23456789",
+			expected: "1.234568",
+		},
+		{
+			name:     "special values are not rounded",
+			opts:     []ericlagergren.NumericOption{ericlagergren.WithTypmod(4, 2)},
+			src:      "-Infinity",
+			expected: "-Infinity",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := &ericlagergren.Numeric{}
+			if err := src.Set(tt.src); err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+
+			binaryFormat, err := src.EncodeBinary(nil, nil)
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+
+			// the rounding must survive the copy made when registering the data type
+			ci := pgtype.NewConnInfo()
+			ci.RegisterDataType(pgtype.DataType{Value: ericlagergren.NewNumeric(tt.opts...), Name: "numeric", OID: pgtype.NumericOID})
+
+			dataType, ok := ci.DataTypeForOID(pgtype.NumericOID)
+			if !ok {
+				t.Fatal("expected numeric data type registered")
+			}
+
+			dst, ok := dataType.Value.(*ericlagergren.Numeric)
+			if !ok {
+				t.Fatalf("expected *ericlagergren.Numeric but got %T", dataType.Value)
+			}
+
+			decoders := map[string]func() error{
+				"text":   func() error { return dst.DecodeText(ci, []byte(tt.src)) },
+				"binary": func() error { return dst.DecodeBinary(ci, binaryFormat) },
+			}
+
+			for format, decode := range decoders {
+				err := decode()
+				if !errors.Is(err, tt.expectErr) {
+					t.Fatalf("%s: expected (%v) but got (%v)", format, tt.expectErr, err)
+				}
+
+				if err != nil {
+					continue
+				}
+
+				if got := dst.Decimal.String(); got != tt.expected {
+					t.Errorf("%s: expected %s but got %s", format, tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNumeric_SetKeepsOptions(t *testing.T) {
+	t.Parallel()
+
+	num := ericlagergren.NewNumeric(ericlagergren.WithTypmod(10, 2))
+	num.MarshalMode = ericlagergren.MarshalString()
+
+	if err := num.Set(int64(7)); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	bytes, err := num.MarshalJSON()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if expected := `"7"`; string(bytes) != expected {
+		t.Errorf("expected %s but got %s", expected, bytes)
+	}
+
+	if err := num.DecodeText(nil, []byte("1.225")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if expected := "1.22"; num.String() != expected {
+		t.Errorf("expected %s but got %s", expected, num.String())
+	}
+}
+
+func TestNumeric_UnmarshalTextKeepsOptions(t *testing.T) {
+	t.Parallel()
+
+	num := ericlagergren.NewNumeric(ericlagergren.WithTypmod(10, 2))
+
+	if err := num.UnmarshalText([]byte("1.5")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	text, err := num.MarshalText()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	// round trip through the null text, the rounding must survive both
+	for _, text := range [][]byte{{}, text} {
+		if err := num.UnmarshalText(text); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+
+	if expected := "1.5"; num.String() != expected {
+		t.Errorf("expected %s but got %s", expected, num.String())
+	}
+
+	if err := num.DecodeText(nil, []byte("1.225")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if expected := "1.22"; num.String() != expected {
+		t.Errorf("expected %s but got %s", expected, num.String())
+	}
+}
@@ -0,0 +1,464 @@
+package decimaltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+// Numeric is implemented by the decimal codecs registered by pginit.WithDecimalType.
+type Numeric interface {
+	pgtype.Value
+	pgtype.TextDecoder
+	pgtype.BinaryDecoder
+	pgtype.TextEncoder
+	pgtype.BinaryEncoder
+	sql.Scanner
+	driver.Valuer
+	json.Marshaler
+	json.Unmarshaler
+}
+
+// Suite describes the decimal codec checked by RunConformance.
+type Suite struct {
+	// New returns an undefined Numeric, as a pointer to the codec type.
+	New func() Numeric
+	// SpecialValues is set when the codec supports NaN, Infinity and -Infinity,
+	// otherwise they must be rejected.
+	SpecialValues bool
+}
+
+// nolint: gochecknoglobals, nolintlint
+var (
+	conformanceValues = []string{
+		"0", "1", "-1", "100000", "0.1", "0.0001", "0.00001", "3.14", "1.50", "-0.00000123", "100010001.0001",
+		"4237234789234789289347892374324872138321894178943189043890124832108934.43219085471578891547854892438945012347981",
+	}
+	specialValues = []string{"NaN", "Infinity", "-Infinity"}
+)
+
+// RunConformance checks that a decimal codec behaves like ericlagergren.Numeric, the transcode
+// subtest requires the database started by Main.
+func RunConformance(t *testing.T, suite Suite) {
+	t.Helper()
+
+	t.Run("Set", func(t *testing.T) { t.Parallel(); testSet(t, suite) })
+	t.Run("Get", func(t *testing.T) { t.Parallel(); testGet(t, suite) })
+	t.Run("AssignTo", func(t *testing.T) { t.Parallel(); testAssignTo(t, suite) })
+	t.Run("EncodeDecode", func(t *testing.T) { t.Parallel(); testEncodeDecode(t, suite) })
+	t.Run("ScanValue", func(t *testing.T) { t.Parallel(); testScanValue(t, suite) })
+	t.Run("JSON", func(t *testing.T) { t.Parallel(); testJSON(t, suite) })
+	t.Run("SpecialValues", func(t *testing.T) { t.Parallel(); testSpecialValues(t, suite) })
+	t.Run("Transcode", func(t *testing.T) { t.Parallel(); testTranscode(t, suite) })
+}
+
+func mustSet(tb testing.TB, suite Suite, src interface{}) Numeric {
+	tb.Helper()
+
+	num := suite.New()
+	if err := num.Set(src); err != nil {
+		tb.Fatalf("expected no error setting %v but got %v", src, err)
+	}
+
+	return num
+}
+
+func mustText(tb testing.TB, num Numeric) string {
+	tb.Helper()
+
+	buf, err := num.EncodeText(nil, nil)
+	if err != nil {
+		tb.Fatalf("expected no error encoding %v but got %v", num, err)
+	}
+
+	return string(buf)
+}
+
+// equalNumeric compares finite values numerically and special values by name.
+func equalNumeric(a, b string) bool {
+	ratA, okA := new(big.Rat).SetString(a)
+	ratB, okB := new(big.Rat).SetString(b)
+
+	if okA && okB {
+		return ratA.Cmp(ratB) == 0
+	}
+
+	return a == b
+}
+
+func testSet(t *testing.T, suite Suite) {
+	t.Helper()
+
+	type _int8 int8
+
+	tests := []struct {
+		source   interface{}
+		expected string
+	}{
+		{source: float32(1), expected: "1"},
+		{source: float64(1.25), expected: "1.25"},
+		{source: float64(12345678900), expected: "12345678900"},
+		{source: int8(-1), expected: "-1"},
+		{source: int16(1), expected: "1"},
+		{source: int32(-1), expected: "-1"},
+		{source: int64(1), expected: "1"},
+		{source: int(-1), expected: "-1"},
+		{source: uint8(1), expected: "1"},
+		{source: uint16(1), expected: "1"},
+		{source: uint32(1), expected: "1"},
+		{source: uint64(math.MaxUint64), expected: "18446744073709551615"},
+		{source: uint(1), expected: "1"},
+		{source: "-1.50", expected: "-1.50"},
+		{source: _int8(1), expected: "1"},
+	}
+
+	for i, tt := range tests {
+		if got := mustText(t, mustSet(t, suite, tt.source)); !equalNumeric(got, tt.expected) {
+			t.Errorf("%d: expected %v to convert to %s, but it was %s", i, tt.source, tt.expected, got)
+		}
+	}
+
+	if got := mustSet(t, suite, nil).Get(); got != nil {
+		t.Errorf("expected nil to convert to null but got %v", got)
+	}
+}
+
+func testGet(t *testing.T, suite Suite) {
+	t.Helper()
+
+	if got := mustSet(t, suite, "3").Get(); got == nil {
+		t.Error("expected a value but got nil")
+	}
+
+	if got := mustSet(t, suite, nil).Get(); got != nil {
+		t.Errorf("expected nil but got %v", got)
+	}
+
+	if got := suite.New().Get(); got != pgtype.Undefined {
+		t.Errorf("expected %v but got %v", pgtype.Undefined, got)
+	}
+}
+
+// nolint: cyclop // table of assignable and failing destinations
+func testAssignTo(t *testing.T, suite Suite) {
+	t.Helper()
+
+	type _int8 int8
+
+	var (
+		i8   int8
+		i16  int16
+		i32  int32
+		i64  int64
+		i    int
+		ui8  uint8
+		ui16 uint16
+		ui32 uint32
+		ui64 uint64
+		ui   uint
+		pi8  *int8
+		_i8  _int8
+		f32  float32
+		f64  float64
+		pf64 *float64
+	)
+
+	simpleTests := []struct {
+		src      string
+		dst      interface{}
+		expected interface{}
+	}{
+		{src: "42", dst: &f32, expected: float32(42)},
+		{src: "4.2", dst: &f64, expected: float64(4.2)},
+		{src: "42", dst: &i16, expected: int16(42)},
+		{src: "42", dst: &i32, expected: int32(42)},
+		{src: "42000", dst: &i64, expected: int64(42000)},
+		{src: "42", dst: &i, expected: int(42)},
+		{src: "42", dst: &ui8, expected: uint8(42)},
+		{src: "42", dst: &ui16, expected: uint16(42)},
+		{src: "42", dst: &ui32, expected: uint32(42)},
+		{src: "42", dst: &ui64, expected: uint64(42)},
+		{src: "42", dst: &ui, expected: uint(42)},
+		{src: "42", dst: &_i8, expected: _int8(42)},
+	}
+
+	for i, tt := range simpleTests {
+		reflect.ValueOf(tt.dst).Elem().Set(reflect.Zero(reflect.TypeOf(tt.dst).Elem()))
+
+		if err := mustSet(t, suite, tt.src).AssignTo(tt.dst); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+
+		if dst := reflect.ValueOf(tt.dst).Elem().Interface(); dst != tt.expected {
+			t.Errorf("%d: expected %v to assign %v, but result was %v", i, tt.src, tt.expected, dst)
+		}
+	}
+
+	if err := mustSet(t, suite, "42").AssignTo(&pf64); err != nil || pf64 == nil || *pf64 != 42 {
+		t.Errorf("expected 42 to be assigned to a new pointer but got %v, %v", pf64, err)
+	}
+
+	if err := mustSet(t, suite, nil).AssignTo(&pi8); err != nil || pi8 != nil {
+		t.Errorf("expected null to assign a nil pointer but got %v, %v", pi8, err)
+	}
+
+	errorTests := []struct {
+		src interface{}
+		dst interface{}
+	}{
+		{src: "150", dst: &i8},
+		{src: "40000", dst: &i16},
+		{src: "-1", dst: &ui8},
+		{src: "-1", dst: &ui64},
+		{src: "-1", dst: &ui},
+		{src: "4.2", dst: &i64},
+		{src: nil, dst: &i32},
+	}
+
+	for i, tt := range errorTests {
+		if err := mustSet(t, suite, tt.src).AssignTo(tt.dst); err == nil {
+			t.Errorf("%d: expected error but none was returned (%v -> %T)", i, tt.src, tt.dst)
+		}
+	}
+}
+
+func testEncodeDecode(t *testing.T, suite Suite) {
+	t.Helper()
+
+	for _, value := range conformanceValues {
+		src := mustSet(t, suite, value)
+
+		text, err := src.EncodeText(nil, nil)
+		if err != nil {
+			t.Fatalf("expected no error encoding %s but got %v", value, err)
+		}
+
+		binary, err := src.EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatalf("expected no error encoding %s but got %v", value, err)
+		}
+
+		fromText, fromBinary := suite.New(), suite.New()
+
+		if err := fromText.DecodeText(nil, text); err != nil {
+			t.Fatalf("expected no error decoding %s but got %v", text, err)
+		}
+
+		if err := fromBinary.DecodeBinary(nil, binary); err != nil {
+			t.Fatalf("expected no error decoding %s but got %v", value, err)
+		}
+
+		for _, got := range []string{mustText(t, fromText), mustText(t, fromBinary)} {
+			if !equalNumeric(got, value) {
+				t.Errorf("expected %s but got %s", value, got)
+			}
+		}
+	}
+
+	null := mustSet(t, suite, nil)
+
+	if buf, err := null.EncodeBinary(nil, nil); err != nil || buf != nil {
+		t.Errorf("expected null to encode to nil but got %v, %v", buf, err)
+	}
+
+	dst := mustSet(t, suite, "1")
+	if err := dst.DecodeBinary(nil, nil); err != nil || dst.Get() != nil {
+		t.Errorf("expected nil to decode to null but got %v, %v", dst.Get(), err)
+	}
+
+	if _, err := suite.New().EncodeText(nil, nil); err == nil {
+		t.Error("expected error encoding undefined")
+	}
+
+	if err := suite.New().DecodeBinary(nil, []byte{0, 1}); err == nil {
+		t.Error("expected error decoding a malformed numeric")
+	}
+}
+
+func testScanValue(t *testing.T, suite Suite) {
+	t.Helper()
+
+	tests := []struct {
+		src      interface{}
+		expected string
+	}{
+		{src: "1.50", expected: "1.50"},
+		{src: []byte("-42"), expected: "-42"},
+		{src: float64(1.25), expected: "1.25"},
+	}
+
+	for i, tt := range tests {
+		dst := suite.New()
+		if err := dst.Scan(tt.src); err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		value, err := dst.Value()
+		if err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		if got, ok := value.(string); !ok || !equalNumeric(got, tt.expected) {
+			t.Errorf("%d: expected %s but got %#v", i, tt.expected, value)
+		}
+	}
+
+	dst := mustSet(t, suite, "1")
+	if err := dst.Scan(nil); err != nil || dst.Get() != nil {
+		t.Errorf("expected nil to scan to null but got %v, %v", dst.Get(), err)
+	}
+
+	if value, err := dst.Value(); err != nil || value != nil {
+		t.Errorf("expected null value but got %v, %v", value, err)
+	}
+
+	if err := suite.New().Scan(true); err == nil {
+		t.Error("expected error scanning a bool")
+	}
+
+	if _, err := suite.New().Value(); err == nil {
+		t.Error("expected error for the value of undefined")
+	}
+}
+
+func testJSON(t *testing.T, suite Suite) {
+	t.Helper()
+
+	for _, value := range []string{"1", "1.50", "-0.00123", "123000"} {
+		got, err := mustSet(t, suite, value).MarshalJSON()
+		if err != nil {
+			t.Fatalf("expected no error marshalling %s but got %v", value, err)
+		}
+
+		// written as a JSON number, not a string
+		var number json.Number
+		if err := json.Unmarshal(got, &number); err != nil || got[0] == '"' || !equalNumeric(number.String(), value) {
+			t.Errorf("expected %s as a JSON number but got %s", value, got)
+		}
+
+		dst := suite.New()
+		if err := dst.UnmarshalJSON(got); err != nil {
+			t.Fatalf("expected no error unmarshalling %s but got %v", got, err)
+		}
+
+		if text := mustText(t, dst); !equalNumeric(text, value) {
+			t.Errorf("expected %s but got %s", value, text)
+		}
+	}
+
+	if got, err := mustSet(t, suite, nil).MarshalJSON(); err != nil || string(got) != "null" {
+		t.Errorf("expected null but got %s, %v", got, err)
+	}
+
+	if _, err := suite.New().MarshalJSON(); err == nil {
+		t.Error("expected error marshalling undefined")
+	}
+
+	dst := mustSet(t, suite, "1")
+	if err := dst.UnmarshalJSON([]byte("null")); err != nil || dst.Get() != nil {
+		t.Errorf("expected null to unmarshal to null but got %v, %v", dst.Get(), err)
+	}
+}
+
+func testSpecialValues(t *testing.T, suite Suite) {
+	t.Helper()
+
+	if !suite.SpecialValues {
+		for _, value := range specialValues {
+			if err := suite.New().Set(value); err == nil {
+				t.Errorf("expected error setting %s", value)
+			}
+
+			if err := suite.New().DecodeText(nil, []byte(value)); err == nil {
+				t.Errorf("expected error decoding %s", value)
+			}
+		}
+
+		for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			if err := suite.New().Set(value); err == nil {
+				t.Errorf("expected error setting %v", value)
+			}
+		}
+
+		// NaN in binary
+		if err := suite.New().DecodeBinary(nil, []byte{0, 0, 0, 0, 0xC0, 0, 0, 0}); err == nil {
+			t.Error("expected error decoding NaN")
+		}
+
+		return
+	}
+
+	for _, value := range specialValues {
+		src := mustSet(t, suite, value)
+
+		binary, err := src.EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatalf("expected no error encoding %s but got %v", value, err)
+		}
+
+		dst := suite.New()
+		if err := dst.DecodeBinary(nil, binary); err != nil {
+			t.Fatalf("expected no error decoding %s but got %v", value, err)
+		}
+
+		if got := mustText(t, dst); got != value {
+			t.Errorf("expected %s but got %s", value, got)
+		}
+
+		if got, err := src.MarshalJSON(); err != nil || string(got) != `"`+value+`"` {
+			t.Errorf("expected %s as a JSON string but got %s, %v", value, got, err)
+		}
+	}
+
+	var f64 float64
+	if err := mustSet(t, suite, "-Infinity").AssignTo(&f64); err != nil || !math.IsInf(f64, -1) {
+		t.Errorf("expected -Infinity to assign -Inf but got %v, %v", f64, err)
+	}
+}
+
+func testTranscode(t *testing.T, suite Suite) {
+	t.Helper()
+
+	values := make([]interface{}, 0, len(conformanceValues)+len(specialValues)+1)
+
+	for _, value := range conformanceValues {
+		values = append(values, mustSet(t, suite, value))
+	}
+
+	if suite.SpecialValues {
+		for _, value := range specialValues {
+			values = append(values, mustSet(t, suite, value))
+		}
+	}
+
+	values = append(values, mustSet(t, suite, nil))
+
+	SuccessfulTranscodeEqFunc(t, "numeric", values, func(a, b interface{}) bool {
+		return equalNumeric(transcodedText(t, a), transcodedText(t, b))
+	})
+}
+
+// transcodedText returns the text of the dereferenced Numeric given to an equality function.
+func transcodedText(tb testing.TB, value interface{}) string {
+	tb.Helper()
+
+	ptr := reflect.New(reflect.TypeOf(value))
+	ptr.Elem().Set(reflect.ValueOf(value))
+
+	num, ok := ptr.Interface().(Numeric)
+	if !ok {
+		tb.Fatalf("cannot convert %T", value)
+	}
+
+	if num.Get() == nil {
+		return "null"
+	}
+
+	return mustText(tb, num)
+}
@@ -0,0 +1,13 @@
+package decimaltest
+
+import (
+	"testing"
+
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
+)
+
+// Main runs the tests of a decimal codec against the Postgres of pgtest.Main,
+// or with the leak detector only when the -leak flag is set.
+func Main(m *testing.M) {
+	pgtest.Main(m)
+}
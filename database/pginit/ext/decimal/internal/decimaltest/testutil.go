@@ -1,10 +1,10 @@
-package ericlagergren_test
+// Package decimaltest holds the test helpers and the conformance suite shared by the decimal codecs.
+package decimaltest
 
 import (
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
 	"reflect"
 	"testing"
 
@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	_ "github.com/lib/pq"
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
 )
 
 func MustConnectDatabaseSQL(tb testing.TB, driverName string) *sql.DB {
@@ -28,7 +29,7 @@ func MustConnectDatabaseSQL(tb testing.TB, driverName string) *sql.DB {
 		tb.Fatalf("Unknown driver %v", driverName)
 	}
 
-	db, err := sql.Open(sqlDriverName, os.Getenv("PGX_TEST_DATABASE"))
+	db, err := sql.Open(sqlDriverName, pgtest.URL(tb))
 	if err != nil {
 		tb.Fatal(err)
 	}
@@ -39,7 +40,7 @@ func MustConnectDatabaseSQL(tb testing.TB, driverName string) *sql.DB {
 func MustConnectPgx(tb testing.TB) *pgx.Conn {
 	tb.Helper()
 
-	conn, err := pgx.Connect(context.Background(), os.Getenv("PGX_TEST_DATABASE"))
+	conn, err := pgx.Connect(context.Background(), pgtest.URL(tb))
 	if err != nil {
 		tb.Fatal(err)
 	}
@@ -0,0 +1,343 @@
+// Package pgnumeric encodes and decodes the Postgres numeric binary format, it is shared
+// by the decimal codecs of pginit so that they do not detour through strings or pgtype.Numeric.
+//
+// Postgres sends numeric values in binary as a header of four uint16, the number of digits,
+// the weight of the first digit, the sign and the display scale, followed by the digits
+// in base 10000, most significant first:
+//
+//	value = sign * sum(digits[i] * 10000^(weight-i))
+package pgnumeric
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+var (
+	// ErrMalformed is returned when decoding bytes that are not a numeric.
+	ErrMalformed = errors.New("malformed numeric")
+	// ErrOutOfRange is returned when encoding a value Postgres cannot store.
+	ErrOutOfRange = errors.New("numeric out of range")
+)
+
+// Form is the kind of a Number.
+type Form uint8
+
+const (
+	// Finite numbers have a coefficient and a scale.
+	Finite Form = iota
+	// NaN is not a number.
+	NaN
+	// Infinity is positive, or negative if Number.Negative is set. It requires Postgres 14.
+	Infinity
+)
+
+const (
+	base            = 10
+	nbase           = 10000
+	nbaseDecDigits  = 4
+	numericHeaderSz = 8
+	// compactNBaseDigits is the number of base 10000 digits always fitting in an uint64.
+	compactNBaseDigits = 4
+	// chunkNBaseDigits base 10000 digits are converted at once from and to big.Int.
+	chunkNBaseDigits = 4
+	chunkPow         = nbase * nbase * nbase * nbase
+
+	numericPos  = 0x0000
+	numericNeg  = 0x4000
+	numericNaN  = 0xC000
+	numericPInf = 0xD000
+	numericNInf = 0xF000
+
+	// maxDScale is the maximum display scale of a Postgres numeric.
+	maxDScale = 0x3FFF
+)
+
+// pow10 holds the powers of ten fitting in an uint64.
+var pow10 = [...]uint64{ // nolint: gochecknoglobals, nolintlint
+	1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19,
+}
+
+// bigChunkPow is 10000^chunkNBaseDigits as a big.Int, it must not be modified.
+var bigChunkPow = new(big.Int).SetUint64(chunkPow) // nolint: gochecknoglobals, nolintlint
+
+// Number is a decimal decomposed as coefficient * 10^-Scale.
+type Number struct {
+	Form     Form
+	Negative bool
+	// Compact is the coefficient when Big is nil.
+	Compact uint64
+	// Big is the absolute coefficient when it does not fit in Compact.
+	Big   *big.Int
+	Scale int
+}
+
+// AppendBinary appends the Postgres binary representation of num to buf.
+// Big may be modified.
+// nolint: cyclop // sign, scale alignment and both coefficient sizes
+func AppendBinary(buf []byte, num Number) ([]byte, error) {
+	switch {
+	case num.Form == NaN:
+		return appendHeader(buf, 0, 0, numericNaN, 0), nil
+	case num.Form == Infinity && num.Negative:
+		return appendHeader(buf, 0, 0, numericNInf, 0), nil
+	case num.Form == Infinity:
+		return appendHeader(buf, 0, 0, numericPInf, 0), nil
+	}
+
+	scale := num.Scale
+
+	dscale := 0
+	if scale > 0 {
+		dscale = scale
+	}
+
+	if dscale > maxDScale {
+		return nil, fmt.Errorf("%w: scale %d", ErrOutOfRange, scale)
+	}
+
+	// align the scale on a multiple of 4 decimal digits, so that the coefficient
+	// is made of whole base 10000 digits
+	pad := (nbaseDecDigits - scale%nbaseDecDigits) % nbaseDecDigits
+	fracDigits := (scale + pad) / nbaseDecDigits
+
+	// base 10000 digits, least significant first
+	var (
+		compactDigits [5]int16
+		digits        []int16
+	)
+
+	if num.Big == nil && num.Compact <= math.MaxUint64/pow10[pad] {
+		digits = compactDigits[:0]
+
+		for mant := num.Compact * pow10[pad]; mant != 0; mant /= nbase {
+			digits = append(digits, int16(mant%nbase))
+		}
+	} else {
+		mant := num.Big
+		if mant == nil {
+			mant = new(big.Int).SetUint64(num.Compact)
+		}
+
+		digits = bigNBaseDigits(mant, pad)
+	}
+
+	if len(digits) == 0 {
+		return appendHeader(buf, 0, 0, numericPos, dscale), nil
+	}
+
+	weight := len(digits) - 1 - fracDigits
+
+	// trailing zero digits are implied by the weight
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+	}
+
+	if weight > math.MaxInt16 || weight < math.MinInt16 || len(digits) > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: weight %d", ErrOutOfRange, weight)
+	}
+
+	sign := uint16(numericPos)
+	if num.Negative {
+		sign = numericNeg
+	}
+
+	buf = appendHeader(buf, len(digits), weight, sign, dscale)
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		buf = appendUint16(buf, uint16(digits[i]))
+	}
+
+	return buf, nil
+}
+
+// bigNBaseDigits returns the base 10000 digits of mant*10^pad, least significant first.
+func bigNBaseDigits(mant *big.Int, pad int) []int16 {
+	if pad > 0 {
+		mant.Mul(mant, new(big.Int).SetUint64(pow10[pad]))
+	}
+
+	digits := make([]int16, 0, (mant.BitLen()/13)+chunkNBaseDigits) // nolint: gomnd // 10000 > 2^13
+	chunk := new(big.Int)
+
+	for mant.Sign() != 0 {
+		mant.QuoRem(mant, bigChunkPow, chunk)
+
+		rem := chunk.Uint64()
+		for i := 0; i < chunkNBaseDigits; i++ {
+			digits = append(digits, int16(rem%nbase))
+			rem /= nbase
+		}
+	}
+
+	// drop the leading zero digits of the last chunk
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+
+	return digits
+}
+
+func appendHeader(buf []byte, ndigits, weight int, sign uint16, dscale int) []byte {
+	buf = appendUint16(buf, uint16(ndigits))
+	buf = appendUint16(buf, uint16(int16(weight)))
+	buf = appendUint16(buf, sign)
+
+	return appendUint16(buf, uint16(dscale))
+}
+
+func appendUint16(buf []byte, value uint16) []byte {
+	return append(buf, byte(value>>8), byte(value)) // nolint: gomnd
+}
+
+// DecodeBinary decodes the numeric in Postgres binary representation src.
+// The scale of the result is the display scale sent by Postgres, integers have their
+// trailing zeros moved to a negative scale, like pgtype.Numeric.
+// nolint: cyclop // header validation and both coefficient sizes
+func DecodeBinary(src []byte) (Number, error) {
+	if len(src) < numericHeaderSz {
+		return Number{}, fmt.Errorf("%w: incomplete %v", ErrMalformed, src)
+	}
+
+	ndigits := int(binary.BigEndian.Uint16(src))
+	weight := int(int16(binary.BigEndian.Uint16(src[2:])))
+	sign := binary.BigEndian.Uint16(src[4:])
+	dscale := int(int16(binary.BigEndian.Uint16(src[6:])))
+	src = src[numericHeaderSz:]
+
+	switch sign {
+	case numericPos, numericNeg:
+	case numericNaN:
+		return Number{Form: NaN}, nil
+	case numericPInf:
+		return Number{Form: Infinity}, nil
+	case numericNInf:
+		return Number{Form: Infinity, Negative: true}, nil
+	default:
+		return Number{}, fmt.Errorf("%w: sign %#x", ErrMalformed, sign)
+	}
+
+	if len(src) < ndigits*2 {
+		return Number{}, fmt.Errorf("%w: incomplete %v", ErrMalformed, src)
+	}
+
+	if ndigits == 0 {
+		return Number{}, nil
+	}
+
+	num := Number{Negative: sign == numericNeg}
+	// scale of the digits as sent
+	scale := (ndigits - weight - 1) * nbaseDecDigits
+
+	if ndigits <= compactNBaseDigits {
+		var mant uint64
+		for i := 0; i < ndigits; i++ {
+			digit := binary.BigEndian.Uint16(src[i*2:])
+			if digit >= nbase {
+				return Number{}, fmt.Errorf("%w: digit %d", ErrMalformed, digit)
+			}
+
+			mant = mant*nbase + uint64(digit)
+		}
+
+		if mant, scale, ok := rescaleCompact(mant, scale, dscale); ok {
+			num.Compact, num.Scale = mant, scale
+
+			return num, nil
+		}
+	}
+
+	mant := new(big.Int)
+	chunk := new(big.Int)
+
+	for i := 0; i < ndigits; i += chunkNBaseDigits {
+		var (
+			value uint64
+			mul   uint64 = 1
+		)
+
+		for j := i; j < ndigits && j < i+chunkNBaseDigits; j++ {
+			digit := binary.BigEndian.Uint16(src[j*2:])
+			if digit >= nbase {
+				return Number{}, fmt.Errorf("%w: digit %d", ErrMalformed, digit)
+			}
+
+			value = value*nbase + uint64(digit)
+			mul *= nbase
+		}
+
+		mant.Mul(mant, chunk.SetUint64(mul))
+		mant.Add(mant, chunk.SetUint64(value))
+	}
+
+	num.Scale = rescaleBig(mant, scale, dscale)
+
+	if mant.IsUint64() {
+		num.Compact = mant.Uint64()
+	} else {
+		num.Big = mant
+	}
+
+	return num, nil
+}
+
+// rescaleCompact sets the scale of mant to dscale, or removes its trailing zeros
+// if it is an integer, it reports false if the result does not fit in an uint64.
+func rescaleCompact(mant uint64, scale, dscale int) (uint64, int, bool) {
+	switch {
+	case dscale > 0 && scale < dscale:
+		shift := dscale - scale
+		if shift >= len(pow10) || mant > math.MaxUint64/pow10[shift] {
+			return 0, 0, false
+		}
+
+		return mant * pow10[shift], dscale, true
+	case dscale > 0 && scale > dscale:
+		shift := scale - dscale
+		if shift >= len(pow10) {
+			return 0, dscale, true
+		}
+
+		return mant / pow10[shift], dscale, true
+	case dscale <= 0 && scale <= 0:
+		for mant != 0 && mant%base == 0 {
+			mant /= base
+			scale--
+		}
+	}
+
+	return mant, scale, true
+}
+
+// rescaleBig is rescaleCompact for coefficients not fitting in an uint64.
+func rescaleBig(mant *big.Int, scale, dscale int) int {
+	switch {
+	case dscale > 0 && scale < dscale:
+		mant.Mul(mant, new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(dscale-scale)), nil))
+
+		return dscale
+	case dscale > 0 && scale > dscale:
+		mant.Quo(mant, new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(scale-dscale)), nil))
+
+		return dscale
+	case dscale <= 0 && scale <= 0:
+		ten := big.NewInt(base)
+		quo, rem := new(big.Int), new(big.Int)
+
+		for mant.Sign() != 0 {
+			quo.QuoRem(mant, ten, rem)
+			if rem.Sign() != 0 {
+				break
+			}
+
+			mant.Set(quo)
+			scale--
+		}
+	}
+
+	return scale
+}
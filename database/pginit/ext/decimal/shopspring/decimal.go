@@ -0,0 +1,440 @@
+// Package shopspring provides a pgtype codec mapping the Postgres numeric type to shopspring/decimal.
+//
+// shopspring/decimal has no NaN nor Infinity, scanning them fails with ErrUnsupportedValue.
+package shopspring
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/pgnumeric"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUndefined = errors.New("cannot encode status undefined")
+	ErrBadStatus = errors.New("invalid status")
+	// ErrUnsupportedValue is returned for NaN, Infinity and -Infinity.
+	ErrUnsupportedValue = errors.New("NaN and Infinity are not supported by shopspring/decimal")
+	errConversionFailed = errors.New("failed to convert")
+	errAssignFailed     = errors.New("failed to assign")
+	errScanFailed       = errors.New("failed to scan")
+)
+
+const (
+	base      = 10
+	bitSize8  = 8
+	bitSize16 = 16
+	bitSize32 = 32
+	bitSize64 = 64
+)
+
+type Numeric struct {
+	Decimal decimal.Decimal
+	Status  pgtype.Status
+}
+
+// nolint: cyclop // need to check each type to make sure all case is covered
+func (dst *Numeric) Set(src interface{}) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	if value, ok := src.(interface{ Get() interface{} }); ok {
+		value2 := value.Get()
+		if value2 != value {
+			return dst.Set(value2)
+		}
+	}
+
+	switch value := src.(type) {
+	case decimal.Decimal:
+		*dst = Numeric{Decimal: value, Status: pgtype.Present}
+	case decimal.NullDecimal:
+		if !value.Valid {
+			*dst = Numeric{Status: pgtype.Null}
+
+			return nil
+		}
+
+		*dst = Numeric{Decimal: value.Decimal, Status: pgtype.Present}
+	case float32:
+		return dst.setFloat64(float64(value))
+	case float64:
+		return dst.setFloat64(value)
+	case int8:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case uint8:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case int16:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case uint16:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case int32:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case uint32:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case int64:
+		*dst = Numeric{Decimal: decimal.NewFromInt(value), Status: pgtype.Present}
+	case uint64:
+		// uint64 could be greater than int64
+		*dst = Numeric{Decimal: decimal.NewFromBigInt(new(big.Int).SetUint64(value), 0), Status: pgtype.Present}
+	case int:
+		*dst = Numeric{Decimal: decimal.NewFromInt(int64(value)), Status: pgtype.Present}
+	case uint:
+		*dst = Numeric{Decimal: decimal.NewFromBigInt(new(big.Int).SetUint64(uint64(value)), 0), Status: pgtype.Present}
+	case string:
+		return dst.DecodeText(nil, []byte(value))
+	default:
+		// If all else fails see if pgtype.Numeric can handle it. If so, translate through that.
+		num := &pgtype.Numeric{}
+		if err := num.Set(value); err != nil {
+			return fmt.Errorf("cannot convert %v to Numeric: %w", value, err)
+		}
+
+		buf, err := num.EncodeText(nil, nil)
+		if err != nil {
+			return fmt.Errorf("cannot convert %v to Numeric: %w", value, err)
+		}
+
+		return dst.DecodeText(nil, buf)
+	}
+
+	return nil
+}
+
+func (dst *Numeric) setFloat64(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("set %v: %w", value, ErrUnsupportedValue)
+	}
+
+	*dst = Numeric{Decimal: decimal.NewFromFloat(value), Status: pgtype.Present}
+
+	return nil
+}
+
+func (dst *Numeric) Get() interface{} {
+	switch {
+	case dst.Status == pgtype.Present:
+		return dst.Decimal
+	case dst.Status == pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// nolint: cyclop, revive, stylecheck // need to check each type to make sure all case is covered
+func (src *Numeric) AssignTo(dst interface{}) error {
+	switch {
+	case src.Status == pgtype.Present:
+		switch val := dst.(type) {
+		case *decimal.Decimal:
+			*val = src.Decimal
+		case *decimal.NullDecimal:
+			*val = decimal.NullDecimal{Decimal: src.Decimal, Valid: true}
+		case *float32:
+			*val = float32(src.Decimal.InexactFloat64())
+		case *float64:
+			*val = src.Decimal.InexactFloat64()
+		case *int:
+			n, err := src.parseInt(strconv.IntSize)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int(n)
+		case *int8:
+			n, err := src.parseInt(bitSize8)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int8(n)
+		case *int16:
+			n, err := src.parseInt(bitSize16)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int16(n)
+		case *int32:
+			n, err := src.parseInt(bitSize32)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = int32(n)
+		case *int64:
+			n, err := src.parseInt(bitSize64)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = n
+		case *uint:
+			n, err := src.parseUint(strconv.IntSize)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint(n)
+		case *uint8:
+			n, err := src.parseUint(bitSize8)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint8(n)
+		case *uint16:
+			n, err := src.parseUint(bitSize16)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint16(n)
+		case *uint32:
+			n, err := src.parseUint(bitSize32)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = uint32(n)
+		case *uint64:
+			n, err := src.parseUint(bitSize64)
+			if err != nil {
+				return fmt.Errorf("%w: %v to %T", err, dst, *val)
+			}
+
+			*val = n
+		default:
+			if nextDst, retry := pgtype.GetAssignToDstType(dst); retry {
+				return src.AssignTo(nextDst)
+			}
+
+			return fmt.Errorf("%w: %T", errAssignFailed, dst)
+		}
+	case src.Status == pgtype.Null:
+		if val, ok := dst.(*decimal.NullDecimal); ok {
+			*val = decimal.NullDecimal{}
+
+			return nil
+		}
+
+		if err := pgtype.NullAssignTo(dst); err != nil {
+			return fmt.Errorf("%w: %T", errAssignFailed, dst)
+		}
+
+		return nil
+	case src.Status == pgtype.Undefined:
+		return fmt.Errorf("AssignTo: %w", ErrUndefined)
+	}
+
+	return nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) parseInt(bitSize int) (int64, error) {
+	if !src.Decimal.IsInteger() {
+		return 0, errConversionFailed
+	}
+
+	n, err := strconv.ParseInt(src.Decimal.String(), base, bitSize)
+	if err != nil {
+		return 0, errConversionFailed
+	}
+
+	return n, nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) parseUint(bitSize int) (uint64, error) {
+	if !src.Decimal.IsInteger() || src.Decimal.Sign() < 0 {
+		return 0, errConversionFailed
+	}
+
+	n, err := strconv.ParseUint(src.Decimal.String(), base, bitSize)
+	if err != nil {
+		return 0, errConversionFailed
+	}
+
+	return n, nil
+}
+
+func (dst *Numeric) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	switch string(src) {
+	case "NaN", "Infinity", "-Infinity":
+		return fmt.Errorf("decode %s: %w", src, ErrUnsupportedValue)
+	}
+
+	dec, err := decimal.NewFromString(string(src))
+	if err != nil {
+		return fmt.Errorf("%w: %s", errConversionFailed, err.Error())
+	}
+
+	*dst = Numeric{Decimal: dec, Status: pgtype.Present}
+
+	return nil
+}
+
+func (dst *Numeric) DecodeBinary(connInfo *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	num, err := pgnumeric.DecodeBinary(src)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errConversionFailed, err.Error())
+	}
+
+	if num.Form != pgnumeric.Finite {
+		return fmt.Errorf("decode: %w", ErrUnsupportedValue)
+	}
+
+	var dec decimal.Decimal
+
+	if num.Big == nil && num.Compact <= math.MaxInt64 {
+		value := int64(num.Compact)
+		if num.Negative {
+			value = -value
+		}
+
+		dec = decimal.New(value, int32(-num.Scale))
+	} else {
+		mant := num.Big
+		if mant == nil {
+			mant = new(big.Int).SetUint64(num.Compact)
+		}
+
+		if num.Negative {
+			mant.Neg(mant)
+		}
+
+		dec = decimal.NewFromBigInt(mant, int32(-num.Scale))
+	}
+
+	*dst = Numeric{Decimal: dec, Status: pgtype.Present}
+
+	return nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch {
+	case src.Status == pgtype.Null:
+		return nil, nil
+	case src.Status == pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	return append(buf, src.Decimal.String()...), nil
+}
+
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) EncodeBinary(connInfo *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch {
+	case src.Status == pgtype.Null:
+		return nil, nil
+	case src.Status == pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	mant := src.Decimal.Coefficient()
+	num := pgnumeric.Number{Negative: mant.Sign() < 0, Scale: -int(src.Decimal.Exponent())}
+
+	mant.Abs(mant)
+
+	if mant.IsUint64() {
+		num.Compact = mant.Uint64()
+	} else {
+		num.Big = mant
+	}
+
+	bytes, err := pgnumeric.AppendBinary(buf, num)
+	if err != nil {
+		return nil, fmt.Errorf("encode %v: %w", src.Decimal, err)
+	}
+
+	return bytes, nil
+}
+
+// Scan implements the database/sql Scanner interface.
+func (dst *Numeric) Scan(src interface{}) error {
+	if src == nil {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	switch src := src.(type) {
+	case float64:
+		return dst.setFloat64(src)
+	case string:
+		return dst.DecodeText(nil, []byte(src))
+	case []byte:
+		return dst.DecodeText(nil, src)
+	}
+
+	return fmt.Errorf("%w %T", errScanFailed, src)
+}
+
+// Value implements the database/sql/driver Valuer interface.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) Value() (driver.Value, error) {
+	switch src.Status {
+	case pgtype.Present:
+		return src.Decimal.String(), nil
+	case pgtype.Null:
+		return nil, nil
+	default:
+		return nil, ErrUndefined
+	}
+}
+
+// MarshalJSON writes the decimal as a JSON number, whatever decimal.MarshalJSONWithoutQuotes.
+// nolint: revive // different naming is to diffrentiate source and destination
+func (src *Numeric) MarshalJSON() ([]byte, error) {
+	switch src.Status {
+	case pgtype.Present:
+		return []byte(src.Decimal.String()), nil
+	case pgtype.Null:
+		return []byte("null"), nil
+	case pgtype.Undefined:
+		return nil, ErrUndefined
+	}
+
+	return nil, ErrBadStatus
+}
+
+// UnmarshalJSON accepts JSON numbers and strings.
+func (dst *Numeric) UnmarshalJSON(bytes []byte) error {
+	if string(bytes) == "null" {
+		*dst = Numeric{Status: pgtype.Null}
+
+		return nil
+	}
+
+	var dec decimal.Decimal
+	if err := dec.UnmarshalJSON(bytes); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	*dst = Numeric{Decimal: dec, Status: pgtype.Present}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package shopspring_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/internal/decimaltest"
+	"github.com/monacohq/golang-common/database/pginit/ext/decimal/shopspring"
+	"github.com/shopspring/decimal"
+)
+
+func TestMain(m *testing.M) {
+	decimaltest.Main(m)
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	decimaltest.RunConformance(t, decimaltest.Suite{
+		New: func() decimaltest.Numeric { return &shopspring.Numeric{} },
+	})
+}
+
+func TestNumericSetAssignToDecimal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		source   interface{}
+		expected shopspring.Numeric
+	}{
+		{
+			source:   decimal.New(15, -1),
+			expected: shopspring.Numeric{Decimal: decimal.New(15, -1), Status: pgtype.Present},
+		},
+		{
+			source:   decimal.NullDecimal{Decimal: decimal.New(15, -1), Valid: true},
+			expected: shopspring.Numeric{Decimal: decimal.New(15, -1), Status: pgtype.Present},
+		},
+		{
+			source:   decimal.NullDecimal{},
+			expected: shopspring.Numeric{Status: pgtype.Null},
+		},
+	}
+
+	for i, tt := range tests {
+		num := &shopspring.Numeric{}
+		if err := num.Set(tt.source); err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		if num.Status != tt.expected.Status || !num.Decimal.Equal(tt.expected.Decimal) {
+			t.Errorf("%d: expected %v to convert to %v, but it was %v", i, tt.source, tt.expected, num)
+		}
+
+		var nullDecimal decimal.NullDecimal
+		if err := num.AssignTo(&nullDecimal); err != nil {
+			t.Errorf("%d: expected no error but got %v", i, err)
+		}
+
+		if nullDecimal.Valid != (tt.expected.Status == pgtype.Present) || !nullDecimal.Decimal.Equal(tt.expected.Decimal) {
+			t.Errorf("%d: expected %v to assign %v, but it was %v", i, num, tt.expected, nullDecimal)
+		}
+	}
+}
+
+func TestNumericUnsupportedValue(t *testing.T) {
+	t.Parallel()
+
+	num := &shopspring.Numeric{}
+
+	if err := num.DecodeText(nil, []byte("NaN")); !errors.Is(err, shopspring.ErrUnsupportedValue) {
+		t.Errorf("expected error %v but got %v", shopspring.ErrUnsupportedValue, err)
+	}
+
+	// -Infinity in binary
+	if err := num.DecodeBinary(nil, []byte{0, 0, 0, 0, 0xF0, 0, 0, 0}); !errors.Is(err, shopspring.ErrUnsupportedValue) {
+		t.Errorf("expected error %v but got %v", shopspring.ErrUnsupportedValue, err)
+	}
+}
@@ -0,0 +1,83 @@
+package pginit
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	errNotStruct = errors.New("not a struct")
+	errNilRow    = errors.New("nil row")
+)
+
+// structFieldsCache caches the fields of the struct types mapped by structFields.
+var structFieldsCache sync.Map // nolint: gochecknoglobals // reflect.Type -> []structField
+
+// structField is a struct field mapped to a column by its db tag.
+type structField struct {
+	column string
+	index  []int
+}
+
+// structFields returns the fields of typ, a struct or a pointer to a struct, tagged with db:"column".
+// Fields tagged db:"-" are skipped and the fields of untagged embedded structs are promoted.
+func structFields(typ reflect.Type) ([]structField, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if cached, ok := structFieldsCache.Load(typ); ok {
+		return cached.([]structField), nil // nolint: forcetypeassert // only []structField is stored
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %v", errNotStruct, typ)
+	}
+
+	fields := appendStructFields(nil, typ, nil)
+	structFieldsCache.Store(typ, fields)
+
+	return fields, nil
+}
+
+func appendStructFields(fields []structField, typ reflect.Type, index []int) []structField {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		tag, ok := field.Tag.Lookup("db")
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				fields = appendStructFields(fields, field.Type, fieldIndex)
+			}
+
+			continue
+		}
+
+		column, _, _ := strings.Cut(tag, ",")
+		if column == "-" || column == "" || !field.IsExported() {
+			continue
+		}
+
+		fields = append(fields, structField{column: column, index: fieldIndex})
+	}
+
+	return fields
+}
+
+// structValue returns the struct value of row, a struct or a pointer to a struct.
+func structValue(row interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(row)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Value{}, errNilRow
+		}
+
+		value = value.Elem()
+	}
+
+	return value, nil
+}
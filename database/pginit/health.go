@@ -0,0 +1,79 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const defaultHealthTimeout = time.Second
+
+// HealthOptions configures Health and the handlers of package health.
+type HealthOptions struct {
+	// Timeout bounds the ping and the probe, default 1s.
+	Timeout time.Duration
+	// Probe is an optional query run after the ping, e.g. "SELECT 1 FROM accounts LIMIT 1"
+	// to check that the schema is migrated.
+	Probe string
+}
+
+// PoolStats are the statistics of a pool reported by Health.
+type PoolStats struct {
+	TotalConns           int32 `json:"total_conns"`
+	AcquiredConns        int32 `json:"acquired_conns"`
+	IdleConns            int32 `json:"idle_conns"`
+	ConstructingConns    int32 `json:"constructing_conns"`
+	MaxConns             int32 `json:"max_conns"`
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	AcquireDurationMs    int64 `json:"acquire_duration_ms"`
+}
+
+// HealthStatus is returned by Health, and written by the handlers of package health when the database is healthy.
+type HealthStatus struct {
+	Status string    `json:"status"`
+	Pool   PoolStats `json:"pool"`
+}
+
+// Health pings pool and runs opts.Probe, both within opts.Timeout, and returns the pool statistics.
+// Package health serves it as a readiness probe.
+func Health(ctx context.Context, pool *pgxpool.Pool, opts HealthOptions) (HealthStatus, error) {
+	timeout := defaultHealthTimeout
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(WithoutTenant(ctx), timeout)
+	defer cancel()
+
+	status := HealthStatus{Status: "ok", Pool: poolStats(pool.Stat())}
+
+	if err := pool.Ping(ctx); err != nil {
+		return status, fmt.Errorf("ping: %w", err)
+	}
+
+	if opts.Probe != "" {
+		if _, err := pool.Exec(ctx, opts.Probe); err != nil {
+			return status, fmt.Errorf("probe: %w", err)
+		}
+	}
+
+	return status, nil
+}
+
+func poolStats(stat *pgxpool.Stat) PoolStats {
+	return PoolStats{
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    stat.AcquireDuration().Milliseconds(),
+	}
+}
@@ -0,0 +1,51 @@
+// Package health serves the health of a pool of pginit as a readiness probe, with handlerwrap.
+// It is separated from pginit so that only the services serving it depend on handlerwrap.
+//
+//	mux.Handle("/health", health.Handler(&logger, pool, pginit.HealthOptions{Probe: "SELECT 1 FROM accounts LIMIT 1"}))
+package health
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/transport/http/handlerwrap/v3"
+	"github.com/rs/zerolog"
+)
+
+// Check returns a handlerwrap.TypedHandler checking pool with pginit.Health.
+// It responds the pginit.HealthStatus with 200 when healthy, and a 503 "database_unavailable"
+// handlerwrap.ErrorResponse otherwise.
+func Check(pool *pgxpool.Pool, opts pginit.HealthOptions) handlerwrap.TypedHandler {
+	return func(req *http.Request) (*handlerwrap.Response, *handlerwrap.ErrorResponse) {
+		headers := map[string]string{"Cache-Control": "no-store"}
+
+		status, err := pginit.Health(req.Context(), pool, opts)
+		if err != nil {
+			return nil, handlerwrap.NewErrorResponse(
+				err,
+				headers,
+				http.StatusServiceUnavailable,
+				"database_unavailable",
+				err.Error(),
+			)
+		}
+
+		return &handlerwrap.Response{
+			Headers:    headers,
+			Body:       status,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// Handler returns a readiness probe handler serving Check with handlerwrap.Wrapper,
+// the failures are logged with logger when it is not nil.
+func Handler(logger *zerolog.Logger, pool *pgxpool.Pool, opts pginit.HealthOptions) http.Handler {
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+
+	return handlerwrap.Wrapper(logger, Check(pool, opts))
+}
@@ -0,0 +1,261 @@
+// Package jobqueue is a durable job queue stored in Postgres, on top of the pools of pginit.
+//
+// Jobs are enqueued with Enqueue, typically within the transaction writing the data they process,
+// so that a job exists if and only if the transaction commits. A WorkerPool runs them with the Worker
+// registered for their kind, up to a concurrency limit per queue, and claims them with
+// FOR UPDATE SKIP LOCKED, so that several replicas can run one. Failed jobs are retried with backoff
+// and discarded after too many attempts.
+//
+// Jobs can be scheduled in the future and made unique, e.g. to replace a cron writing to a table.
+// With WithListener, the workers are woken up by LISTEN/NOTIFY as soon as a job is enqueued
+// instead of waiting for the next poll.
+//
+//	client := jobqueue.New()
+//
+//	err := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+//		_, err := client.Enqueue(ctx, tx, "send_email", email, jobqueue.EnqueueOptions{})
+//
+//		return err
+//	})
+//
+//	workers := client.NewWorkerPool(pool,
+//		jobqueue.WithWorker("send_email", jobqueue.WorkerFunc(sendEmail)),
+//		jobqueue.WithQueue("default", 5),
+//		jobqueue.WithListener(pgi.NewListener()),
+//	)
+//	go workers.Run(ctx)
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+const (
+	defaultTable       = "jobs"
+	defaultQueue       = "default"
+	defaultMaxAttempts = 10
+)
+
+var (
+	// ErrMissingKind is returned by Enqueue for a job without kind.
+	ErrMissingKind = errors.New("jobqueue: missing kind")
+	// ErrDuplicateJob is returned by Enqueue when a job of the same kind and unique key
+	// is already available or running, the job is not enqueued.
+	ErrDuplicateJob = errors.New("jobqueue: duplicate job")
+)
+
+// State is the state of a job in the jobs table.
+type State string
+
+const (
+	// StateAvailable jobs are waiting to be run, at their scheduled time.
+	StateAvailable State = "available"
+	// StateRunning jobs are being run by a worker.
+	StateRunning State = "running"
+	// StateCompleted jobs were run successfully.
+	StateCompleted State = "completed"
+	// StateDiscarded jobs failed too many times and are not retried anymore.
+	StateDiscarded State = "discarded"
+)
+
+// DB runs the statements of Enqueue and of a WorkerPool, it is implemented by *pgxpool.Pool, *pgx.Conn, pgx.Tx,
+// *pginit.ManagedPool and *pginit.TenantPool.
+type DB interface {
+	pginit.Querier
+	pginit.Execer
+}
+
+// Job is a job run by a Worker.
+type Job struct {
+	ID    int64  `db:"id"`
+	Queue string `db:"queue"`
+	Kind  string `db:"kind"`
+	// Args are the JSON encoded arguments given to Enqueue.
+	Args json.RawMessage `db:"args"`
+	// Attempt is the number of the current attempt, starting at 1.
+	Attempt     int       `db:"attempt"`
+	MaxAttempts int       `db:"max_attempts"`
+	ScheduledAt time.Time `db:"scheduled_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// UnmarshalArgs decodes the arguments of the job into v.
+func (j *Job) UnmarshalArgs(v interface{}) error {
+	if err := json.Unmarshal(j.Args, v); err != nil {
+		return fmt.Errorf("unmarshal args: %w", err)
+	}
+
+	return nil
+}
+
+// EnqueueOptions configures Enqueue.
+type EnqueueOptions struct {
+	// Queue is the queue of the job, default "default".
+	Queue string
+	// ScheduledAt is when the job can run, default now.
+	ScheduledAt time.Time
+	// Delay postpones the job from now, it is ignored when ScheduledAt is set.
+	Delay time.Duration
+	// MaxAttempts is the number of attempts after which the job is discarded, default 10.
+	MaxAttempts int
+	// UniqueKey makes Enqueue fail with ErrDuplicateJob while a job of the same kind
+	// and unique key is available or running, e.g. the day of a daily report.
+	UniqueKey string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTable set the jobs table, default jobs.
+// It can be qualified with a schema, e.g. myschema.jobs.
+func WithTable(table string) Option {
+	return func(c *Client) {
+		c.table = table
+	}
+}
+
+// Client enqueues jobs and creates the WorkerPool running them.
+type Client struct {
+	table string
+}
+
+// New returns a Client.
+func New(opts ...Option) *Client {
+	c := &Client{
+		table: defaultTable,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// CreateTable creates the jobs table if it does not exist, services using migrations
+// should rather copy its definition into one of them.
+func (c *Client) CreateTable(ctx context.Context, db pginit.Execer) error {
+	if _, err := db.Exec(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (
+			id bigserial PRIMARY KEY,
+			queue text NOT NULL,
+			kind text NOT NULL,
+			args jsonb NOT NULL,
+			state text NOT NULL DEFAULT 'available',
+			attempt integer NOT NULL DEFAULT 0,
+			max_attempts integer NOT NULL,
+			unique_key text,
+			last_error text,
+			scheduled_at timestamptz NOT NULL DEFAULT now(),
+			attempted_at timestamptz,
+			finalized_at timestamptz,
+			created_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		c.tableIdentifier(),
+	)); err != nil {
+		return fmt.Errorf("create table: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (queue, scheduled_at, id) WHERE state = 'available'",
+		c.indexIdentifier("available"),
+		c.tableIdentifier(),
+	)); err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, fmt.Sprintf(
+		"CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (kind, unique_key) WHERE state IN ('available', 'running')",
+		c.indexIdentifier("unique"),
+		c.tableIdentifier(),
+	)); err != nil {
+		return fmt.Errorf("create unique index: %w", err)
+	}
+
+	return nil
+}
+
+// Enqueue stores a job of kind with its JSON encoded args within db, typically a transaction,
+// and returns its id. Workers listening for jobs are notified when db commits.
+func (c *Client) Enqueue(ctx context.Context, db DB, kind string, args interface{}, opts EnqueueOptions) (int64, error) {
+	if kind == "" {
+		return 0, ErrMissingKind
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return 0, fmt.Errorf("marshal args: %w", err)
+	}
+
+	if opts.Queue == "" {
+		opts.Queue = defaultQueue
+	}
+
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+
+	var scheduledAt interface{}
+	if !opts.ScheduledAt.IsZero() {
+		scheduledAt = opts.ScheduledAt
+	}
+
+	var uniqueKey interface{}
+	if opts.UniqueKey != "" {
+		uniqueKey = opts.UniqueKey
+	}
+
+	id, err := pginit.Get[int64](ctx, db,
+		fmt.Sprintf(
+			`INSERT INTO %s (queue, kind, args, max_attempts, unique_key, scheduled_at)
+			VALUES ($1, $2, $3, $4, $5, coalesce($6, now() + $7::bigint * interval '1 microsecond'))
+			ON CONFLICT (kind, unique_key) WHERE state IN ('available', 'running') DO NOTHING
+			RETURNING id`,
+			c.tableIdentifier(),
+		),
+		opts.Queue, kind, string(encoded), opts.MaxAttempts, uniqueKey, scheduledAt, opts.Delay.Microseconds(),
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrDuplicateJob
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("insert job: %w", err)
+	}
+
+	// scheduled jobs are found by polling
+	if opts.ScheduledAt.IsZero() && opts.Delay <= 0 {
+		if err := pginit.Notify(ctx, db, c.channel(), opts.Queue); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (c *Client) tableIdentifier() string {
+	return pgx.Identifier(strings.Split(c.table, ".")).Sanitize()
+}
+
+func (c *Client) tableName() string {
+	parts := strings.Split(c.table, ".")
+
+	return parts[len(parts)-1]
+}
+
+func (c *Client) indexIdentifier(suffix string) string {
+	return pgx.Identifier{c.tableName() + "_" + suffix + "_idx"}.Sanitize()
+}
+
+// channel is notified with the queue of the jobs available right away.
+func (c *Client) channel() string {
+	return c.tableName() + "_available"
+}
@@ -0,0 +1,335 @@
+package jobqueue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/jobqueue"
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+type email struct {
+	To string `json:"to"`
+}
+
+func newClient(t *testing.T) (*jobqueue.Client, *pginit.PGInit, *pgxpool.Pool) {
+	t.Helper()
+
+	pgi := pgtest.New(t)
+
+	pool, err := pgi.ConnPool(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	client := jobqueue.New()
+
+	if err := client.CreateTable(context.Background(), pool); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	return client, pgi, pool
+}
+
+// run runs workers until the test ends.
+func run(t *testing.T, workers *jobqueue.WorkerPool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- workers.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled but got: %v", err)
+		}
+	})
+}
+
+func state(t *testing.T, pool *pgxpool.Pool, id int64) (jobqueue.State, int) {
+	t.Helper()
+
+	var (
+		st      jobqueue.State
+		attempt int
+	)
+
+	if err := pool.QueryRow(context.Background(), "SELECT state, attempt FROM jobs WHERE id = $1", id).
+		Scan(&st, &attempt); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	return st, attempt
+}
+
+func waitState(t *testing.T, pool *pgxpool.Pool, id int64, want jobqueue.State) int {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		st, attempt := state(t, pool, id)
+		if st == want {
+			return attempt
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected job %d to be %s but got %s", id, want, st)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEnqueue_MissingKind(t *testing.T) {
+	t.Parallel()
+
+	if _, err := jobqueue.New().Enqueue(context.Background(), nil, "", nil, jobqueue.EnqueueOptions{}); !errors.Is(err, jobqueue.ErrMissingKind) {
+		t.Errorf("expected ErrMissingKind but got: %v", err)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client, pgi, pool := newClient(t)
+
+	var (
+		mu         sync.Mutex
+		recipients []string
+		running    int32
+		maxRunning int32
+	)
+
+	workers := client.NewWorkerPool(pool,
+		jobqueue.WithWorker("send_email", jobqueue.WorkerFunc(func(ctx context.Context, job *jobqueue.Job) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				peak := atomic.LoadInt32(&maxRunning)
+				if n <= peak || atomic.CompareAndSwapInt32(&maxRunning, peak, n) {
+					break
+				}
+			}
+
+			var args email
+			if err := job.UnmarshalArgs(&args); err != nil {
+				return err
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			recipients = append(recipients, args.To)
+			mu.Unlock()
+
+			return nil
+		})),
+		jobqueue.WithQueue("default", 2),
+		// only the notifications wake up the workers
+		jobqueue.WithPollInterval(time.Hour),
+		jobqueue.WithListener(pgi.NewListener()),
+	)
+	run(t, workers)
+
+	// lets the listener LISTEN before the notifications
+	time.Sleep(500 * time.Millisecond)
+
+	var ids []int64
+
+	err := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+		for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			id, err := client.Enqueue(ctx, tx, "send_email", email{To: to}, jobqueue.EnqueueOptions{})
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	for _, id := range ids {
+		if attempt := waitState(t, pool, id, jobqueue.StateCompleted); attempt != 1 {
+			t.Errorf("expected 1 attempt but got %d", attempt)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(recipients) != 3 {
+		t.Errorf("expected 3 emails sent but got %v", recipients)
+	}
+
+	if peak := atomic.LoadInt32(&maxRunning); peak > 2 {
+		t.Errorf("expected at most 2 jobs at a time but got %d", peak)
+	}
+}
+
+func TestWorkerPool_RetryAndDiscard(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client, _, pool := newClient(t)
+
+	retried, err := client.Enqueue(ctx, pool, "flaky", nil, jobqueue.EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	discarded, err := client.Enqueue(ctx, pool, "broken", nil, jobqueue.EnqueueOptions{MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	workers := client.NewWorkerPool(pool,
+		jobqueue.WithWorker("flaky", jobqueue.WorkerFunc(func(ctx context.Context, job *jobqueue.Job) error {
+			if job.Attempt == 1 {
+				return errors.New("temporary failure")
+			}
+
+			return nil
+		})),
+		jobqueue.WithWorker("broken", jobqueue.WorkerFunc(func(ctx context.Context, job *jobqueue.Job) error {
+			panic("broken worker")
+		})),
+		jobqueue.WithPollInterval(10*time.Millisecond),
+		jobqueue.WithBackoff(10*time.Millisecond, 10*time.Millisecond),
+	)
+	run(t, workers)
+
+	if attempt := waitState(t, pool, retried, jobqueue.StateCompleted); attempt != 2 {
+		t.Errorf("expected 2 attempts but got %d", attempt)
+	}
+
+	if attempt := waitState(t, pool, discarded, jobqueue.StateDiscarded); attempt != 2 {
+		t.Errorf("expected 2 attempts but got %d", attempt)
+	}
+}
+
+func TestEnqueue_UniqueAndScheduled(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client, _, pool := newClient(t)
+
+	opts := jobqueue.EnqueueOptions{UniqueKey: "2026-10-18", Delay: time.Hour}
+
+	id, err := client.Enqueue(ctx, pool, "daily_report", nil, opts)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := client.Enqueue(ctx, pool, "daily_report", nil, opts); !errors.Is(err, jobqueue.ErrDuplicateJob) {
+		t.Errorf("expected ErrDuplicateJob but got: %v", err)
+	}
+
+	workers := client.NewWorkerPool(pool,
+		jobqueue.WithWorker("daily_report", jobqueue.WorkerFunc(func(context.Context, *jobqueue.Job) error {
+			t.Error("expected the scheduled job not to run yet")
+
+			return nil
+		})),
+		jobqueue.WithPollInterval(10*time.Millisecond),
+	)
+	run(t, workers)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if st, attempt := state(t, pool, id); st != jobqueue.StateAvailable || attempt != 0 {
+		t.Errorf("expected an available job without attempts but got %s, %d", st, attempt)
+	}
+}
+
+func TestWorkerPool_RescuedWhileRunning(t *testing.T) {
+	t.Parallel()
+
+	client, _, pool := newClient(t)
+
+	id, err := client.Enqueue(context.Background(), pool, "slow", nil, jobqueue.EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	var (
+		secondStarted = make(chan struct{})
+		firstReturned = make(chan struct{})
+		releaseSecond = make(chan struct{})
+		releaseOnce   sync.Once
+	)
+
+	t.Cleanup(func() { releaseOnce.Do(func() { close(releaseSecond) }) })
+
+	workers := client.NewWorkerPool(pool,
+		jobqueue.WithWorker("slow", jobqueue.WorkerFunc(func(ctx context.Context, job *jobqueue.Job) error {
+			switch job.Attempt {
+			case 1:
+				// outlives the rescue, until the second attempt runs
+				select {
+				case <-secondStarted:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				defer close(firstReturned)
+
+				return nil
+			case 2:
+				close(secondStarted)
+				<-releaseSecond
+
+				return nil
+			default:
+				t.Errorf("unexpected attempt %d", job.Attempt)
+
+				return nil
+			}
+		})),
+		jobqueue.WithPollInterval(10*time.Millisecond),
+		jobqueue.WithRescueAfter(500*time.Millisecond),
+	)
+	run(t, workers)
+
+	select {
+	case <-firstReturned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the job to be rescued and run again")
+	}
+	// lets the first attempt record its outcome
+	time.Sleep(100 * time.Millisecond)
+
+	if st, attempt := state(t, pool, id); st != jobqueue.StateRunning || attempt != 2 {
+		t.Errorf("expected the second attempt running but got %s, %d", st, attempt)
+	}
+
+	releaseOnce.Do(func() { close(releaseSecond) })
+
+	if attempt := waitState(t, pool, id, jobqueue.StateCompleted); attempt != 2 {
+		t.Errorf("expected 2 attempts but got %d", attempt)
+	}
+}
@@ -0,0 +1,443 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultConcurrency  = 10
+	defaultPollInterval = time.Second
+	defaultBackoff      = time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultRescueAfter  = time.Hour
+	defaultRetention    = 24 * time.Hour
+
+	maxMaintenancePeriod = time.Minute
+	finalizeTimeout      = 5 * time.Second
+)
+
+var errWorkerPanic = errors.New("worker panic")
+
+// Worker runs the jobs of a kind. Its ctx is cancelled when the WorkerPool stops,
+// the job is then run again later.
+type Worker interface {
+	Work(ctx context.Context, job *Job) error
+}
+
+// WorkerFunc is a function implementing Worker.
+type WorkerFunc func(ctx context.Context, job *Job) error
+
+// Work calls f.
+func (f WorkerFunc) Work(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// PoolOption configures a WorkerPool.
+type PoolOption func(*WorkerPool)
+
+// WithWorker runs the jobs of kind with worker, only the kinds with a worker are run.
+func WithWorker(kind string, worker Worker) PoolOption {
+	return func(wp *WorkerPool) {
+		wp.workers[kind] = worker
+	}
+}
+
+// WithQueue runs the jobs of queue, at most concurrency at a time.
+// Without it, only the default queue is run, at most 10 jobs at a time.
+func WithQueue(queue string, concurrency int) PoolOption {
+	return func(wp *WorkerPool) {
+		if concurrency > 0 {
+			wp.queues[queue] = concurrency
+		}
+	}
+}
+
+// WithPollInterval set how often the queues are polled for available jobs, default 1s.
+// With WithListener, polling only finds the scheduled and retried jobs.
+func WithPollInterval(interval time.Duration) PoolOption {
+	return func(wp *WorkerPool) {
+		if interval > 0 {
+			wp.pollInterval = interval
+		}
+	}
+}
+
+// WithBackoff set the delay before retrying a failed job, doubled at each attempt up to max
+// with jitter, see pginit.Backoff, default 1s up to 1h.
+func WithBackoff(base, max time.Duration) PoolOption {
+	return func(wp *WorkerPool) {
+		if base > 0 {
+			wp.backoff = base
+		}
+
+		wp.maxBackoff = max
+		if wp.maxBackoff < wp.backoff {
+			wp.maxBackoff = wp.backoff
+		}
+	}
+}
+
+// WithListener wakes up the workers with LISTEN/NOTIFY as soon as a job is enqueued.
+// The listener is run by Run and must not be run elsewhere.
+func WithListener(listener *pginit.Listener) PoolOption {
+	return func(wp *WorkerPool) {
+		wp.listener = listener
+	}
+}
+
+// WithRescueAfter set how long a job can be running before it is considered lost with its replica
+// and run again, default 1h. It must be longer than the longest job: the outcome of a run
+// outliving it is not recorded, only the outcome of the latest attempt is.
+func WithRescueAfter(d time.Duration) PoolOption {
+	return func(wp *WorkerPool) {
+		if d > 0 {
+			wp.rescueAfter = d
+		}
+	}
+}
+
+// WithRetention set how long the completed jobs are kept, default 24h.
+func WithRetention(d time.Duration) PoolOption {
+	return func(wp *WorkerPool) {
+		if d > 0 {
+			wp.retention = d
+		}
+	}
+}
+
+// WithLogger logs the failed jobs and the errors of the database.
+func WithLogger(logger *zerolog.Logger) PoolOption {
+	return func(wp *WorkerPool) {
+		wp.logger = logger
+	}
+}
+
+// WorkerPool runs the jobs of its queues with the registered workers.
+type WorkerPool struct {
+	client *Client
+	pool   DB
+
+	workers      map[string]Worker
+	queues       map[string]int
+	pollInterval time.Duration
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	listener     *pginit.Listener
+	rescueAfter  time.Duration
+	retention    time.Duration
+	logger       *zerolog.Logger
+
+	wake map[string]chan struct{}
+}
+
+// NewWorkerPool returns a WorkerPool running the jobs of c with pool, e.g. a *pgxpool.Pool from PGInit.ConnPool,
+// a *pginit.ManagedPool or a *pginit.TenantPool.
+func (c *Client) NewWorkerPool(pool DB, opts ...PoolOption) *WorkerPool {
+	wp := &WorkerPool{
+		client:       c,
+		pool:         pool,
+		workers:      map[string]Worker{},
+		queues:       map[string]int{},
+		pollInterval: defaultPollInterval,
+		backoff:      defaultBackoff,
+		maxBackoff:   defaultMaxBackoff,
+		rescueAfter:  defaultRescueAfter,
+		retention:    defaultRetention,
+	}
+
+	for _, opt := range opts {
+		opt(wp)
+	}
+
+	if len(wp.queues) == 0 {
+		wp.queues[defaultQueue] = defaultConcurrency
+	}
+
+	wp.wake = make(map[string]chan struct{}, len(wp.queues))
+	for queue := range wp.queues {
+		wp.wake[queue] = make(chan struct{}, 1)
+	}
+
+	return wp
+}
+
+// Run runs jobs until ctx is done, then waits for the running jobs, whose ctx is cancelled, to return.
+// It always returns the error of ctx.
+func (wp *WorkerPool) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	if wp.listener != nil {
+		wp.listener.Handle(wp.client.channel(), func(n pginit.Notification) {
+			wp.signal(n.Payload)
+		})
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_ = wp.listener.Run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		wp.maintain(ctx)
+	}()
+
+	for queue, concurrency := range wp.queues {
+		wg.Add(1)
+
+		go func(queue string, concurrency int) {
+			defer wg.Done()
+
+			wp.work(ctx, queue, concurrency)
+		}(queue, concurrency)
+	}
+
+	wg.Wait()
+
+	return fmt.Errorf("worker pool: %w", ctx.Err())
+}
+
+// signal wakes up the fetcher of queue, if it is run.
+func (wp *WorkerPool) signal(queue string) {
+	if wake, ok := wp.wake[queue]; ok {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// work fetches and runs the jobs of queue until ctx is done.
+func (wp *WorkerPool) work(ctx context.Context, queue string, concurrency int) {
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	slots := make(chan struct{}, concurrency)
+
+	for {
+		if free := concurrency - len(slots); free > 0 {
+			jobs, err := wp.fetch(ctx, queue, free)
+			if err != nil && ctx.Err() == nil {
+				wp.logError(err, "jobqueue: fetch jobs")
+			}
+
+			for _, job := range jobs {
+				slots <- struct{}{}
+
+				running.Add(1)
+
+				go func(job *Job) {
+					defer running.Done()
+
+					wp.execute(ctx, job)
+
+					<-slots
+					wp.signal(queue)
+				}(job)
+			}
+		}
+
+		timer := time.NewTimer(wp.pollInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-wp.wake[queue]:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// fetch claims up to limit available jobs of queue having a worker.
+func (wp *WorkerPool) fetch(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	kinds := make([]string, 0, len(wp.workers))
+	for kind := range wp.workers {
+		kinds = append(kinds, kind)
+	}
+
+	jobs, err := pginit.Select[*Job](pginit.WithoutTenant(ctx), wp.pool,
+		fmt.Sprintf(
+			`UPDATE %[1]s SET state = $1, attempt = attempt + 1, attempted_at = now()
+			WHERE id IN (
+				SELECT id FROM %[1]s
+				WHERE state = $2 AND queue = $3 AND kind = ANY($4) AND scheduled_at <= now()
+				ORDER BY scheduled_at, id LIMIT $5 FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, queue, kind, args, attempt, max_attempts, scheduled_at, created_at`,
+			wp.client.tableIdentifier(),
+		),
+		StateRunning, StateAvailable, queue, kinds, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// execute runs job and records the outcome.
+func (wp *WorkerPool) execute(ctx context.Context, job *Job) {
+	errWork := wp.runWorker(ctx, job)
+
+	// the outcome is recorded even when the pool stops
+	finalizeCtx, cancel := context.WithTimeout(pginit.WithoutTenant(context.Background()), finalizeTimeout)
+	defer cancel()
+
+	var err error
+
+	switch {
+	case errWork == nil:
+		_, err = wp.finalize(finalizeCtx, job, "state = $1, finalized_at = now()", StateCompleted)
+	case ctx.Err() != nil:
+		// interrupted by the shutdown, the attempt does not count
+		_, err = wp.finalize(finalizeCtx, job, "state = $1, attempt = attempt - 1", StateAvailable)
+	default:
+		err = wp.fail(finalizeCtx, job, errWork)
+	}
+
+	if err != nil {
+		wp.logError(err, "jobqueue: record job outcome")
+	}
+}
+
+// finalize updates job with the assignments of set, unless it was rescued meanwhile: the job is then
+// available again or run by another attempt, whose outcome must not be overwritten.
+// It returns whether job was updated.
+func (wp *WorkerPool) finalize(ctx context.Context, job *Job, set string, args ...interface{}) (bool, error) {
+	n := len(args)
+
+	commandTag, err := wp.pool.Exec(ctx,
+		fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND state = $%d AND attempt = $%d",
+			wp.client.tableIdentifier(), set, n+1, n+2, n+3),
+		append(args, job.ID, StateRunning, job.Attempt)...,
+	)
+	if err != nil {
+		return false, fmt.Errorf("finalize job: %w", err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		if wp.logger != nil {
+			wp.logger.Warn().
+				Int64("job_id", job.ID).
+				Str("kind", job.Kind).
+				Int("attempt", job.Attempt).
+				Msg("jobqueue: job outcome not recorded, the job was rescued while running")
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (wp *WorkerPool) runWorker(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errWorkerPanic, r)
+		}
+	}()
+
+	return wp.workers[job.Kind].Work(ctx, job)
+}
+
+// fail schedules the retry of job, or discards it after its last attempt.
+func (wp *WorkerPool) fail(ctx context.Context, job *Job, errWork error) error {
+	state := StateAvailable
+	if job.Attempt >= job.MaxAttempts {
+		state = StateDiscarded
+	}
+
+	updated, err := wp.finalize(ctx, job,
+		`state = $1, last_error = $2,
+		scheduled_at = now() + $3::bigint * interval '1 microsecond',
+		finalized_at = CASE WHEN $1 = 'discarded' THEN now() END`,
+		state, errWork.Error(), pginit.Backoff(wp.backoff, wp.maxBackoff, job.Attempt-1).Microseconds(),
+	)
+	if err != nil || !updated {
+		return err
+	}
+
+	if wp.logger != nil {
+		event := wp.logger.Warn()
+		msg := "jobqueue: job failed, will retry"
+
+		if state == StateDiscarded {
+			event = wp.logger.Error()
+			msg = "jobqueue: job failed, discarded"
+		}
+
+		event.Err(errWork).
+			Int64("job_id", job.ID).
+			Str("kind", job.Kind).
+			Int("attempt", job.Attempt).
+			Msg(msg)
+	}
+
+	return nil
+}
+
+// maintain rescues the jobs of dead replicas and deletes the old completed jobs until ctx is done.
+func (wp *WorkerPool) maintain(ctx context.Context) {
+	ctx = pginit.WithoutTenant(ctx)
+
+	period := wp.rescueAfter
+	if period > maxMaintenancePeriod {
+		period = maxMaintenancePeriod
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		if _, err := wp.pool.Exec(ctx,
+			fmt.Sprintf(
+				`UPDATE %s SET state = CASE WHEN attempt >= max_attempts THEN $1 ELSE $2 END,
+				last_error = 'rescued: running for too long',
+				finalized_at = CASE WHEN attempt >= max_attempts THEN now() END
+				WHERE state = $3 AND attempted_at < now() - $4::bigint * interval '1 microsecond'`,
+				wp.client.tableIdentifier(),
+			),
+			StateDiscarded, StateAvailable, StateRunning, wp.rescueAfter.Microseconds(),
+		); err != nil && ctx.Err() == nil {
+			wp.logError(err, "jobqueue: rescue jobs")
+		}
+
+		if _, err := wp.pool.Exec(ctx,
+			fmt.Sprintf(
+				"DELETE FROM %s WHERE state = $1 AND finalized_at < now() - $2::bigint * interval '1 microsecond'",
+				wp.client.tableIdentifier(),
+			),
+			StateCompleted, wp.retention.Microseconds(),
+		); err != nil && ctx.Err() == nil {
+			wp.logError(err, "jobqueue: delete completed jobs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (wp *WorkerPool) logError(err error, msg string) {
+	if wp.logger != nil {
+		wp.logger.Error().Err(err).Msg(msg)
+	}
+}
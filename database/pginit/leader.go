@@ -0,0 +1,76 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+)
+
+// LeaderCallbacks are called by RunLeaderElection.
+type LeaderCallbacks struct {
+	// OnElected is called in a goroutine when the leadership is gained,
+	// its ctx is cancelled when the leadership is lost or RunLeaderElection returns.
+	OnElected func(ctx context.Context)
+	// OnDemoted is called when the leadership is lost, after OnElected returned.
+	OnDemoted func()
+}
+
+// RunLeaderElection campaigns for the advisory lock key with the other replicas until ctx is done:
+// the replica holding the lock is the leader and keeps the leadership until its connection dies
+// or ctx is done, even if OnElected returns. It always returns the error of ctx.
+//
+// The leader only notices the loss of its connection on the next check of the lock, see WithLockCheckPeriod:
+// after a network split, the server can release the lock of a leader it considers gone and elect another replica
+// while the former leader still runs OnElected, for up to the check period plus the 5s timeout of the check.
+// The work of OnElected should then be idempotent or fenced, e.g. by the rows it locks.
+func RunLeaderElection(
+	ctx context.Context, pool Acquirer, key int64, callbacks LeaderCallbacks, opts ...LockOption,
+) error {
+	for {
+		lock, err := Lock(ctx, pool, key, opts...)
+		if err == nil {
+			err = lock.Lock(ctx)
+			if err == nil {
+				lead(ctx, lock, callbacks)
+			}
+
+			lock.Close()
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("leader election: %w", ctx.Err())
+		}
+
+		// the database is unreachable, waits before campaigning again
+		if err != nil {
+			if errWait := sleepContext(ctx, defaultLockCheckPeriod); errWait != nil {
+				return fmt.Errorf("leader election: %w", errWait)
+			}
+		}
+	}
+}
+
+// lead runs the callbacks while lock is held and ctx is not done.
+func lead(ctx context.Context, lock *AdvisoryLock, callbacks LeaderCallbacks) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if callbacks.OnElected != nil {
+			callbacks.OnElected(leaderCtx)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-lock.Lost():
+	}
+
+	cancel()
+	<-done
+
+	if callbacks.OnDemoted != nil {
+		callbacks.OnDemoted()
+	}
+}
@@ -0,0 +1,361 @@
+package pginit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultListenerBackoff    = 100 * time.Millisecond
+	defaultListenerBufferSize = 64
+)
+
+// Execer executes sql, it is implemented by *pgxpool.Pool, *pgx.Conn, pgx.Tx and *Router.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Notification is a message received on a listened channel.
+type Notification struct {
+	Channel string
+	Payload string
+	// PID is the process id of the server session that sent the notification.
+	PID uint32
+}
+
+// Unmarshal decodes a JSON payload sent by Notify into v.
+func (n Notification) Unmarshal(v interface{}) error {
+	if err := json.Unmarshal([]byte(n.Payload), v); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	return nil
+}
+
+// Gap reports that notifications sent on Channels between Since and Until may have been missed
+// because the listener connection was lost.
+type Gap struct {
+	Channels []string
+	Since    time.Time
+	Until    time.Time
+	Err      error
+}
+
+// ListenerOption configures Listener behaviour.
+type ListenerOption func(*Listener)
+
+// Listener holds a dedicated connection, outside of any pool, to LISTEN on channels
+// and deliver notifications to Go channels or callbacks.
+// When the connection is lost it reconnects with backoff, LISTENs again on every channel
+// and reports the Gap during which notifications may have been missed.
+type Listener struct {
+	connConfig    *pgx.ConnConfig
+	beforeConnect func(context.Context, *pgx.ConnConfig) error
+	logger        *zerolog.Logger
+	backoff       time.Duration
+	bufferSize    int
+	onGap         func(Gap)
+
+	mu          sync.Mutex
+	handlers    map[string][]func(Notification)
+	subscribers []chan Notification
+	listened    map[string]bool
+	wake        context.CancelFunc
+}
+
+// NewListener returns a Listener connecting with the same configuration as the pools.
+// Call Listen or Handle to subscribe to channels, then Run to start receiving.
+func (pgi *PGInit) NewListener(opts ...ListenerOption) *Listener {
+	l := &Listener{
+		connConfig:    pgi.pgxConf.ConnConfig.Copy(),
+		beforeConnect: pgi.pgxConf.BeforeConnect,
+		logger:        pgi.logger,
+		backoff:       defaultListenerBackoff,
+		bufferSize:    defaultListenerBufferSize,
+		handlers:      map[string][]func(Notification){},
+		listened:      map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithListenerBackoff set the initial delay between reconnection attempts,
+// doubled and jittered at each failed attempt.
+func WithListenerBackoff(backoff time.Duration) ListenerOption {
+	return func(l *Listener) {
+		l.backoff = backoff
+	}
+}
+
+// WithListenerBufferSize set the buffer size of the Go channels returned by Listen.
+func WithListenerBufferSize(size int) ListenerOption {
+	return func(l *Listener) {
+		l.bufferSize = size
+	}
+}
+
+// WithGapHandler set a callback called after a reconnection with the Gap during which
+// notifications may have been missed, so that the state can be resynchronised from the tables.
+func WithGapHandler(onGap func(Gap)) ListenerOption {
+	return func(l *Listener) {
+		l.onGap = onGap
+	}
+}
+
+// Listen subscribes to channel and returns a Go channel receiving its notifications.
+// The Go channel is closed when Run returns. A full Go channel blocks the delivery
+// of every other notification, so it must be drained.
+func (l *Listener) Listen(channel string) <-chan Notification {
+	notifications := make(chan Notification, l.bufferSize)
+
+	l.mu.Lock()
+	l.subscribers = append(l.subscribers, notifications)
+	l.mu.Unlock()
+
+	l.Handle(channel, func(n Notification) {
+		notifications <- n
+	})
+
+	return notifications
+}
+
+// Handle subscribes to channel and calls fn for each of its notifications,
+// from the goroutine running Run.
+func (l *Listener) Handle(channel string, fn func(Notification)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.handlers[channel] = append(l.handlers[channel], fn)
+
+	// interrupt the wait so that the LISTEN is sent right away
+	if l.wake != nil {
+		l.wake()
+	}
+}
+
+// Run receives notifications until ctx is done, reconnecting when the connection is lost.
+// It always returns the error of ctx.
+func (l *Listener) Run(ctx context.Context) error {
+	defer l.closeSubscribers()
+
+	var disconnectedAt time.Time
+
+	var lastErr error
+
+	for attempt := 0; ; {
+		conn, err := l.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("listener: %w", ctx.Err())
+			}
+
+			l.logError(err, "pginit: listener connect failed")
+
+			if disconnectedAt.IsZero() {
+				disconnectedAt = time.Now()
+			}
+
+			lastErr = err
+
+			if errWait := sleepContext(ctx, jitteredBackoff(l.backoff, attempt)); errWait != nil {
+				return fmt.Errorf("listener: %w", errWait)
+			}
+
+			attempt++
+
+			continue
+		}
+
+		attempt = 0
+
+		err = l.serve(ctx, conn, func() {
+			if !disconnectedAt.IsZero() {
+				l.reportGap(disconnectedAt, lastErr)
+				disconnectedAt = time.Time{}
+			}
+		})
+
+		_ = conn.Close(context.Background())
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("listener: %w", ctx.Err())
+		}
+
+		l.logError(err, "pginit: listener connection lost")
+
+		disconnectedAt = time.Now()
+		lastErr = err
+	}
+}
+
+func (l *Listener) connect(ctx context.Context) (*pgx.Conn, error) {
+	connConfig := l.connConfig.Copy()
+
+	if l.beforeConnect != nil {
+		if err := l.beforeConnect(ctx, connConfig); err != nil {
+			return nil, fmt.Errorf("before connect: %w", err)
+		}
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("connect config: %w", err)
+	}
+
+	return conn, nil
+}
+
+// serve LISTENs and dispatches notifications until the connection fails or ctx is done.
+// onListening is called once every channel is listened.
+func (l *Listener) serve(ctx context.Context, conn *pgx.Conn, onListening func()) error {
+	l.mu.Lock()
+	l.listened = map[string]bool{}
+	l.mu.Unlock()
+
+	for {
+		waitCtx, cancel := context.WithCancel(ctx)
+
+		l.mu.Lock()
+		l.wake = cancel
+
+		pending := make([]string, 0, len(l.handlers))
+
+		for channel := range l.handlers {
+			if !l.listened[channel] {
+				pending = append(pending, channel)
+			}
+		}
+		l.mu.Unlock()
+
+		for _, channel := range pending {
+			if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				cancel()
+
+				return fmt.Errorf("listen %s: %w", channel, err)
+			}
+
+			l.mu.Lock()
+			l.listened[channel] = true
+			l.mu.Unlock()
+		}
+
+		onListening()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+
+		l.mu.Lock()
+		l.wake = nil
+		l.mu.Unlock()
+		cancel()
+
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.Canceled) && !conn.IsClosed() {
+				// woken up by Handle
+				continue
+			}
+
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+
+		l.dispatch(Notification{
+			Channel: notification.Channel,
+			Payload: notification.Payload,
+			PID:     notification.PID,
+		})
+	}
+}
+
+func (l *Listener) dispatch(notification Notification) {
+	l.mu.Lock()
+	handlers := append([]func(Notification){}, l.handlers[notification.Channel]...)
+	l.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(notification)
+	}
+}
+
+func (l *Listener) reportGap(since time.Time, err error) {
+	l.mu.Lock()
+	gap := Gap{
+		Channels: make([]string, 0, len(l.handlers)),
+		Since:    since,
+		Until:    time.Now(),
+		Err:      err,
+	}
+
+	for channel := range l.handlers {
+		gap.Channels = append(gap.Channels, channel)
+	}
+	l.mu.Unlock()
+
+	if l.logger != nil {
+		l.logger.Warn().
+			Err(err).
+			Strs("channels", gap.Channels).
+			Time("since", gap.Since).
+			Time("until", gap.Until).
+			Msg("pginit: listener reconnected, notifications may have been missed")
+	}
+
+	if l.onGap != nil {
+		l.onGap(gap)
+	}
+}
+
+func (l *Listener) closeSubscribers() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, subscriber := range l.subscribers {
+		close(subscriber)
+	}
+
+	l.subscribers = nil
+	l.handlers = map[string][]func(Notification){}
+}
+
+func (l *Listener) logError(err error, msg string) {
+	if l.logger != nil {
+		l.logger.Error().Err(err).Msg(msg)
+	}
+}
+
+// Notify sends payload on channel with pg_notify, so that it is delivered when db commits.
+// Strings and byte slices are sent as is, other payloads are JSON encoded
+// and can be decoded with Notification.Unmarshal.
+func Notify[T any](ctx context.Context, db Execer, channel string, payload T) error {
+	var encoded string
+
+	switch value := any(payload).(type) {
+	case string:
+		encoded = value
+	case []byte:
+		encoded = string(value)
+	default:
+		bytes, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("marshal payload: %w", err)
+		}
+
+		encoded = string(bytes)
+	}
+
+	if _, err := db.Exec(ctx, "SELECT pg_notify($1, $2)", channel, encoded); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+type orderEvent struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pgi := newTestPGInit(t)
+	pool := newTestPool(t)
+
+	listener := pgi.NewListener()
+	notifications := listener.Listen("listener_orders")
+
+	received := make(chan orderEvent, 1)
+
+	listener.Handle("listener_typed", func(n pginit.Notification) {
+		var event orderEvent
+		if err := n.Unmarshal(&event); err != nil {
+			t.Errorf("expected no error but got: %v", err)
+		}
+
+		received <- event
+	})
+
+	done := make(chan error, 1)
+
+	go func() { done <- listener.Run(ctx) }()
+
+	// LISTEN is sent asynchronously, notify until it is received
+	var notification pginit.Notification
+
+	for notification.Payload == "" {
+		if err := pginit.Notify(ctx, pool, "listener_orders", "raw payload"); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		select {
+		case notification = <-notifications:
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("expected notification before timeout")
+		}
+	}
+
+	if notification.Channel != "listener_orders" || notification.Payload != "raw payload" {
+		t.Errorf("expected raw payload on listener_orders but got %+v", notification)
+	}
+
+	want := orderEvent{ID: "b7202eb0-5bf0-475d-8ee2-d3d2c168a5d5", Status: "filled"}
+
+	var event orderEvent
+
+	for event.ID == "" {
+		if err := pginit.Notify(ctx, pool, "listener_typed", want); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		select {
+		case event = <-received:
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("expected notification before timeout")
+		}
+	}
+
+	if event != want {
+		t.Errorf("expected %+v but got %+v", want, event)
+	}
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected (%v) but got (%v)", context.Canceled, err)
+	}
+
+	if _, ok := <-notifications; ok {
+		t.Error("expected notifications channel closed")
+	}
+}
+
+func TestListener_Reconnect(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pgi := newTestPGInit(t)
+	pool := newTestPool(t)
+
+	gaps := make(chan pginit.Gap, 1)
+
+	listener := pgi.NewListener(
+		pginit.WithListenerBackoff(10*time.Millisecond),
+		pginit.WithGapHandler(func(gap pginit.Gap) { gaps <- gap }),
+	)
+	notifications := listener.Listen("listener_reconnect")
+
+	go func() { _ = listener.Run(ctx) }()
+
+	// the payload is unique to each wait, so that a notification buffered before the reconnect
+	// is not mistaken for one received after it
+	waitNotification := func(payload string) {
+		t.Helper()
+
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			if err := pginit.Notify(ctx, pool, "listener_reconnect", payload); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			for waiting := true; waiting; {
+				select {
+				case notification := <-notifications:
+					if notification.Payload == payload {
+						return
+					}
+				case <-ticker.C:
+					waiting = false
+				case <-ctx.Done():
+					t.Fatal("expected notification before timeout")
+				}
+			}
+		}
+	}
+
+	waitNotification("before reconnect")
+
+	if _, err := pool.Exec(
+		ctx,
+		`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = 'LISTEN "listener_reconnect"'`,
+	); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	select {
+	case gap := <-gaps:
+		if len(gap.Channels) != 1 || gap.Channels[0] != "listener_reconnect" || gap.Until.Before(gap.Since) {
+			t.Errorf("expected gap on listener_reconnect but got %+v", gap)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected gap before timeout")
+	}
+
+	waitNotification("after reconnect")
+}
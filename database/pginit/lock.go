@@ -0,0 +1,335 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	defaultLockCheckPeriod = time.Second
+	defaultLockRetryPeriod = 100 * time.Millisecond
+	lockCheckTimeout       = 5 * time.Second
+)
+
+var (
+	// ErrLockLost is returned by the methods of an AdvisoryLock whose connection died,
+	// the lock is released by the server and the AdvisoryLock cannot be used anymore.
+	ErrLockLost = errors.New("advisory lock lost")
+	// ErrLockClosed is returned by the methods of a closed AdvisoryLock.
+	ErrLockClosed = errors.New("advisory lock closed")
+)
+
+// Acquirer acquires connections, it is implemented by *pgxpool.Pool and *ManagedPool.
+type Acquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// LockOption configures an AdvisoryLock.
+type LockOption func(*AdvisoryLock)
+
+// WithLockCheckPeriod set how often the connection of a held lock is checked, default 1s.
+func WithLockCheckPeriod(period time.Duration) LockOption {
+	return func(l *AdvisoryLock) {
+		if period > 0 {
+			l.checkPeriod = period
+		}
+	}
+}
+
+// WithLockRetryPeriod set how often Lock retries to take a lock held by another session, default 100ms.
+func WithLockRetryPeriod(period time.Duration) LockOption {
+	return func(l *AdvisoryLock) {
+		if period > 0 {
+			l.retryPeriod = period
+		}
+	}
+}
+
+// LockKey derives an advisory lock key from name, e.g. LockKey("cron/daily-report").
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("pginit/lock:" + name))
+
+	return int64(h.Sum64())
+}
+
+// AdvisoryLock is a session level Postgres advisory lock pinned to a dedicated connection,
+// so that it is held as long as the connection is alive. It is safe for concurrent use.
+type AdvisoryLock struct {
+	key         int64
+	checkPeriod time.Duration
+	retryPeriod time.Duration
+
+	mu     sync.Mutex
+	conn   *pgxpool.Conn
+	held   bool
+	lost   atomic.Value // chan struct{}, read by Lost without mu
+	err    error
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+
+	// connMu serializes the use of conn by the check and the methods, which hold mu before it
+	connMu sync.Mutex
+}
+
+// Lock acquires a dedicated connection of pool for the advisory lock key, the lock is not taken yet,
+// see TryLock and Lock. Close must be called to release the connection.
+func Lock(ctx context.Context, pool Acquirer, key int64, opts ...LockOption) (*AdvisoryLock, error) {
+	conn, err := pool.Acquire(WithoutTenant(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("acquire: %w", err)
+	}
+
+	lock := &AdvisoryLock{
+		key:         key,
+		checkPeriod: defaultLockCheckPeriod,
+		retryPeriod: defaultLockRetryPeriod,
+		conn:        conn,
+	}
+
+	for _, opt := range opts {
+		opt(lock)
+	}
+
+	return lock, nil
+}
+
+// TryLock takes the lock if it is free and reports whether it is held.
+func (l *AdvisoryLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.usable(); err != nil {
+		return false, err
+	}
+
+	if l.held {
+		return true, nil
+	}
+
+	var acquired bool
+
+	l.connMu.Lock()
+	err := l.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired)
+	l.connMu.Unlock()
+
+	if err != nil {
+		return false, l.fail(fmt.Errorf("try advisory lock: %w", err))
+	}
+
+	if acquired {
+		l.hold()
+	}
+
+	return acquired, nil
+}
+
+// Lock waits until the lock is taken or ctx is done, e.g. with a timeout.
+// It polls with pg_try_advisory_lock, so that a timeout does not break the connection.
+func (l *AdvisoryLock) Lock(ctx context.Context) error {
+	ticker := time.NewTicker(l.retryPeriod)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := l.TryLock(ctx)
+		if err != nil {
+			return err
+		}
+
+		if acquired {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("advisory lock: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Unlock releases the lock, the connection is kept for the next TryLock or Lock.
+// If the unlock fails, the connection is closed so that the server releases the lock,
+// and the AdvisoryLock cannot be used anymore.
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.usable(); err != nil {
+		return err
+	}
+
+	if !l.held {
+		return nil
+	}
+
+	l.connMu.Lock()
+
+	_, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if err != nil {
+		// the server may still hold the lock, it must not go back to the pool with the connection
+		_ = l.conn.Conn().Close(context.Background())
+	}
+
+	l.connMu.Unlock()
+
+	if err != nil {
+		return l.fail(fmt.Errorf("advisory unlock: %w", err))
+	}
+
+	l.release()
+
+	return nil
+}
+
+// Lost returns a channel closed when the held lock is lost because its connection died,
+// it is nil when the lock is not held and was not lost. It does not wait for a running check.
+func (l *AdvisoryLock) Lost() <-chan struct{} {
+	lost, _ := l.lost.Load().(chan struct{})
+
+	return lost
+}
+
+// Close releases the lock, if held, and the connection.
+func (l *AdvisoryLock) Close() {
+	l.mu.Lock()
+
+	if l.closed {
+		l.mu.Unlock()
+
+		return
+	}
+
+	l.closed = true
+	held := l.held && l.err == nil
+
+	if l.held {
+		l.release()
+	}
+
+	l.mu.Unlock()
+
+	// waits for the check without the mutex it takes
+	l.wg.Wait()
+
+	if held {
+		ctx, cancel := context.WithTimeout(context.Background(), lockCheckTimeout)
+		defer cancel()
+
+		// the lock must not go back to the pool with the connection
+		if _, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+			_ = l.conn.Conn().Close(ctx)
+		}
+	}
+
+	l.conn.Release()
+}
+
+func (l *AdvisoryLock) usable() error {
+	switch {
+	case l.closed:
+		return ErrLockClosed
+	case l.err != nil:
+		return l.err
+	}
+
+	return nil
+}
+
+// hold marks the lock held and starts checking its connection, l.mu must be held.
+func (l *AdvisoryLock) hold() {
+	l.held = true
+	l.lost.Store(make(chan struct{}))
+	l.stop = make(chan struct{})
+
+	l.wg.Add(1)
+
+	go l.check(l.stop)
+}
+
+// release marks the lock released and stops the check, l.mu must be held.
+func (l *AdvisoryLock) release() {
+	l.held = false
+	l.lost.Store((chan struct{})(nil))
+	close(l.stop)
+}
+
+// fail marks the lock lost after err on its connection, l.mu must be held.
+func (l *AdvisoryLock) fail(err error) error {
+	l.connMu.Lock()
+	closed := l.conn.Conn().IsClosed()
+	l.connMu.Unlock()
+
+	if !closed {
+		return err
+	}
+
+	l.err = fmt.Errorf("%w: %v", ErrLockLost, err) // nolint: errorlint // ErrLockLost is the error to check
+
+	if l.held {
+		// l.lost stays closed, so that Lost reports the loss
+		close(l.lost.Load().(chan struct{})) // nolint: forcetypeassert // stored by hold
+		l.held = false
+		close(l.stop)
+	}
+
+	return l.err
+}
+
+// check pings the connection of the held lock every checkPeriod until stop is closed,
+// without holding l.mu so that Lost and a held TryLock do not wait for a ping.
+func (l *AdvisoryLock) check(stop chan struct{}) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.checkPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		// the connection is busy with a query of TryLock or Unlock, which reports its failure
+		if !l.connMu.TryLock() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), lockCheckTimeout)
+		err := l.conn.Conn().Ping(ctx)
+		cancel()
+
+		if err != nil {
+			// a ping timeout closes the connection too
+			_ = l.conn.Conn().Close(context.Background())
+		}
+
+		l.connMu.Unlock()
+
+		if err == nil {
+			continue
+		}
+
+		l.mu.Lock()
+
+		// the lock may have been released during the ping
+		select {
+		case <-stop:
+		default:
+			_ = l.fail(err)
+		}
+
+		l.mu.Unlock()
+
+		return
+	}
+}
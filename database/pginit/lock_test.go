@@ -0,0 +1,192 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestAdvisoryLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := newTestPoolWithMaxConns(t, 4)
+	key := pginit.LockKey(t.Name())
+
+	first, err := pginit.Lock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer first.Close()
+
+	second, err := pginit.Lock(ctx, pool, key, pginit.WithLockRetryPeriod(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer second.Close()
+
+	if acquired, err := first.TryLock(ctx); err != nil || !acquired {
+		t.Fatalf("expected the lock to be taken but got %v, %v", acquired, err)
+	}
+
+	if acquired, err := second.TryLock(ctx); err != nil || acquired {
+		t.Fatalf("expected the lock to be busy but got %v, %v", acquired, err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	if err := second.Lock(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded but got: %v", err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if err := second.Lock(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if first.Lost() != nil {
+		t.Error("expected no lost channel for an unlocked lock")
+	}
+}
+
+func TestAdvisoryLock_Lost(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := newTestPoolWithMaxConns(t, 4)
+
+	lock, err := pginit.Lock(ctx, pool, pginit.LockKey(t.Name()), pginit.WithLockCheckPeriod(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer lock.Close()
+
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := pool.Exec(ctx,
+		"SELECT pg_terminate_backend(pid) FROM pg_locks "+
+			"WHERE locktype = 'advisory' AND (classid::bigint << 32 | objid::bigint) = $1",
+		pginit.LockKey(t.Name()),
+	); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lock to be lost")
+	}
+
+	if _, err := lock.TryLock(ctx); !errors.Is(err, pginit.ErrLockLost) {
+		t.Errorf("expected ErrLockLost but got: %v", err)
+	}
+}
+
+func TestRunLeaderElection(t *testing.T) {
+	t.Parallel()
+
+	pool := newTestPoolWithMaxConns(t, 4)
+	key := pginit.LockKey(t.Name())
+
+	var leaders int32
+
+	elected := make(chan int, 2)
+
+	run := func(ctx context.Context, id int) chan error {
+		done := make(chan error, 1)
+
+		go func() {
+			done <- pginit.RunLeaderElection(ctx, pool, key, pginit.LeaderCallbacks{
+				OnElected: func(ctx context.Context) {
+					if atomic.AddInt32(&leaders, 1) > 1 {
+						t.Error("expected a single leader")
+					}
+
+					elected <- id
+
+					<-ctx.Done()
+				},
+				OnDemoted: func() {
+					atomic.AddInt32(&leaders, -1)
+				},
+			}, pginit.WithLockRetryPeriod(10*time.Millisecond))
+		}()
+
+		return done
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	done := map[int]chan error{1: run(ctx1, 1), 2: run(ctx2, 2)}
+	cancels := map[int]context.CancelFunc{1: cancel1, 2: cancel2}
+
+	first := <-elected
+	cancels[first]()
+
+	if err := <-done[first]; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got: %v", err)
+	}
+
+	select {
+	case second := <-elected:
+		if second == first {
+			t.Errorf("expected the other replica to be elected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the other replica to be elected")
+	}
+}
+
+func TestAdvisoryLock_UnlockFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := newTestPoolWithMaxConns(t, 4)
+	key := pginit.LockKey(t.Name())
+
+	lock, err := pginit.Lock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := lock.Unlock(cancelledCtx); !errors.Is(err, pginit.ErrLockLost) {
+		t.Fatalf("expected ErrLockLost but got: %v", err)
+	}
+
+	lock.Close()
+
+	other, err := pginit.Lock(ctx, pool, key, pginit.WithLockRetryPeriod(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer other.Close()
+
+	// the server releases the lock when the closed session ends
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelTimeout()
+
+	if err := other.Lock(timeoutCtx); err != nil {
+		t.Errorf("expected the lock to be released with the connection but got: %v", err)
+	}
+}
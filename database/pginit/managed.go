@@ -0,0 +1,326 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	shutdownPollInterval = 10 * time.Millisecond
+	shutdownCancelGrace  = 5 * time.Second
+)
+
+// ErrPoolShuttingDown is returned by the methods of a ManagedPool once Shutdown is called.
+var ErrPoolShuttingDown = errors.New("pool is shutting down")
+
+// ManagedPool is a pool that can be shut down gracefully, see Shutdown.
+// It implements Execer, Querier, TxBeginner and CopyFromer.
+type ManagedPool struct {
+	pool     *pgxpool.Pool
+	pgi      *PGInit
+	maxConns int
+	closed   int32
+
+	mu    sync.Mutex
+	conns map[*pgx.Conn]struct{}
+}
+
+// ManagedPool initiates connection to database like ConnPool and returns a ManagedPool.
+func (pgi *PGInit) ManagedPool(ctx context.Context) (*ManagedPool, error) {
+	conf := pgi.pgxConf.Copy()
+	managed := &ManagedPool{pgi: pgi, maxConns: int(conf.MaxConns), conns: map[*pgx.Conn]struct{}{}}
+
+	beforeAcquire := conf.BeforeAcquire
+	conf.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if beforeAcquire != nil && !beforeAcquire(ctx, conn) {
+			return false
+		}
+
+		managed.track(conn)
+
+		return true
+	}
+
+	pool, err := pgi.connPool(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	managed.pool = pool
+
+	return managed, nil
+}
+
+// track records conn to cancel its query on Shutdown, the closed connections are forgotten
+// once there are more recorded connections than the pool can hold.
+func (mp *ManagedPool) track(conn *pgx.Conn) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.conns[conn] = struct{}{}
+
+	if len(mp.conns) <= mp.maxConns {
+		return
+	}
+
+	for tracked := range mp.conns {
+		if isCleanedUp(tracked) {
+			delete(mp.conns, tracked)
+		}
+	}
+}
+
+// isCleanedUp reports whether conn is closed, it is safe to call while conn is used by another goroutine.
+func isCleanedUp(conn *pgx.Conn) bool {
+	select {
+	case <-conn.PgConn().CleanupDone():
+		return true
+	default:
+		return false
+	}
+}
+
+func (mp *ManagedPool) shuttingDown() bool {
+	return atomic.LoadInt32(&mp.closed) == 1
+}
+
+// Pool returns the underlying pool, acquisitions made with it are not refused by Shutdown.
+func (mp *ManagedPool) Pool() *pgxpool.Pool {
+	return mp.pool
+}
+
+// Stat returns the statistics of the underlying pool.
+func (mp *ManagedPool) Stat() *pgxpool.Stat {
+	return mp.pool.Stat()
+}
+
+// Acquire returns a connection of the pool, to be released.
+func (mp *ManagedPool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if mp.shuttingDown() {
+		return nil, ErrPoolShuttingDown
+	}
+
+	conn, err := mp.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Ping acquires a connection and pings the database.
+func (mp *ManagedPool) Ping(ctx context.Context) error {
+	if mp.shuttingDown() {
+		return ErrPoolShuttingDown
+	}
+
+	if err := mp.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	return nil
+}
+
+// Exec runs sql.
+func (mp *ManagedPool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if mp.shuttingDown() {
+		return nil, ErrPoolShuttingDown
+	}
+
+	tag, err := mp.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, fmt.Errorf("exec: %w", err)
+	}
+
+	return tag, nil
+}
+
+// Query runs sql, the connection is in flight until the rows are closed.
+func (mp *ManagedPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if mp.shuttingDown() {
+		return nil, ErrPoolShuttingDown
+	}
+
+	rows, err := mp.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return rows, nil
+}
+
+// errRow is a pgx.Row failing with err.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
+
+// QueryRow runs sql, the error of a shut down pool is returned by Scan.
+func (mp *ManagedPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if mp.shuttingDown() {
+		return errRow{err: ErrPoolShuttingDown}
+	}
+
+	return mp.pool.QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction, the connection is in flight until it is committed or rolled back.
+func (mp *ManagedPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return mp.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction with txOptions.
+func (mp *ManagedPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if mp.shuttingDown() {
+		return nil, ErrPoolShuttingDown
+	}
+
+	tx, err := mp.pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	return tx, nil
+}
+
+// CopyFrom copies rowSrc into tableName.
+func (mp *ManagedPool) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if mp.shuttingDown() {
+		return 0, ErrPoolShuttingDown
+	}
+
+	copied, err := mp.pool.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		return copied, fmt.Errorf("copy from: %w", err)
+	}
+
+	return copied, nil
+}
+
+// Close closes the pool right away, without draining, see Shutdown.
+func (mp *ManagedPool) Close() {
+	atomic.StoreInt32(&mp.closed, 1)
+	mp.pool.Close()
+}
+
+// Shutdown refuses new acquisitions with ErrPoolShuttingDown, waits for the in-flight connections
+// to be released until ctx is done, cancels the queries still running and closes the pool.
+// The connections still acquired 5s after the cancellation, e.g. idle in a transaction, are closed.
+// It returns an error wrapping ctx.Err() when queries had to be cancelled,
+// and logs a summary with the logger given to WithLogger.
+func (mp *ManagedPool) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&mp.closed, 0, 1) {
+		return ErrPoolShuttingDown
+	}
+
+	start := time.Now()
+	inFlight := mp.pool.Stat().AcquiredConns()
+
+	var (
+		cancelled int32
+		err       error
+	)
+
+	if errWait := mp.waitReleased(ctx); errWait != nil {
+		cancelled = mp.pool.Stat().AcquiredConns()
+		err = fmt.Errorf("shutdown: %w", errWait)
+
+		mp.cancelQueries()
+
+		graceCtx, cancel := context.WithTimeout(context.Background(), shutdownCancelGrace)
+		defer cancel()
+
+		// a connection idle in a transaction or with rows never closed ignores the cancel request
+		if errGrace := mp.waitReleased(graceCtx); errGrace != nil {
+			err = fmt.Errorf("shutdown: connections still acquired: %w", errGrace)
+
+			mp.closeConns()
+		}
+	}
+
+	mp.pool.Close()
+	mp.logSummary(inFlight, cancelled, start, err)
+
+	return err
+}
+
+func (mp *ManagedPool) waitReleased(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for mp.pool.Stat().AcquiredConns() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() // nolint: wrapcheck // wrapped by Shutdown
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// cancelQueries asks the server to cancel the query running on each connection,
+// it is a no-op on the idle ones.
+func (mp *ManagedPool) cancelQueries() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownCancelGrace)
+	defer cancel()
+
+	for conn := range mp.conns {
+		if isCleanedUp(conn) {
+			continue
+		}
+
+		if err := conn.PgConn().CancelRequest(ctx); err != nil && mp.pgi.logger != nil {
+			mp.pgi.logger.Warn().Err(err).Msg("pginit: cancel query")
+		}
+	}
+}
+
+// closeConns closes the network connection of each connection still acquired, their holders get
+// an error on their next use and the pool destroys them on release.
+func (mp *ManagedPool) closeConns() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for conn := range mp.conns {
+		if isCleanedUp(conn) {
+			continue
+		}
+
+		// unlike PgConn().Close, closing the net.Conn is safe while conn is used by another goroutine
+		if err := conn.PgConn().Conn().Close(); err != nil && mp.pgi.logger != nil {
+			mp.pgi.logger.Warn().Err(err).Msg("pginit: close connection")
+		}
+	}
+}
+
+func (mp *ManagedPool) logSummary(inFlight, cancelled int32, start time.Time, err error) {
+	if mp.pgi.logger == nil {
+		return
+	}
+
+	event := mp.pgi.logger.Info()
+	if err != nil {
+		event = mp.pgi.logger.Warn().Err(err)
+	}
+
+	event.
+		Int32("in_flight", inFlight).
+		Int32("cancelled", cancelled).
+		Dur("duration", time.Since(start)).
+		Msg("pginit: pool shut down")
+}
@@ -0,0 +1,128 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"go.uber.org/goleak"
+)
+
+// nolint: paralleltest // goleak checks the goroutines of the whole process
+func TestManagedPool_Shutdown(t *testing.T) {
+	tests := []struct {
+		name            string
+		sleep           time.Duration
+		deadline        time.Duration
+		expectCancelled bool
+	}{
+		{
+			name:     "in-flight query is drained",
+			sleep:    200 * time.Millisecond,
+			deadline: 5 * time.Second,
+		},
+		{
+			name:            "query past the deadline is cancelled",
+			sleep:           time.Minute,
+			deadline:        200 * time.Millisecond,
+			expectCancelled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+			ctx := context.Background()
+
+			pool, err := newTestPGInit(t).ManagedPool(ctx)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			queryErr := make(chan error, 1)
+
+			go func() {
+				_, err := pool.Exec(ctx, "SELECT pg_sleep($1)", tt.sleep.Seconds())
+				queryErr <- err
+			}()
+
+			// lets the query acquire its connection
+			for pool.Stat().AcquiredConns() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(ctx, tt.deadline)
+			defer cancel()
+
+			errShutdown := pool.Shutdown(shutdownCtx)
+
+			if _, err := pool.Exec(ctx, "SELECT 1"); !errors.Is(err, pginit.ErrPoolShuttingDown) {
+				t.Errorf("expected ErrPoolShuttingDown but got: %v", err)
+			}
+
+			err = <-queryErr
+
+			if tt.expectCancelled {
+				if !errors.Is(errShutdown, context.DeadlineExceeded) {
+					t.Errorf("expected context.DeadlineExceeded but got: %v", errShutdown)
+				}
+
+				if err == nil {
+					t.Error("expected the query to be cancelled")
+				}
+
+				return
+			}
+
+			if errShutdown != nil {
+				t.Errorf("expected no error but got: %v", errShutdown)
+			}
+
+			if err != nil {
+				t.Errorf("expected the query to complete but got: %v", err)
+			}
+		})
+	}
+}
+
+// nolint: paralleltest // goleak checks the goroutines of the whole process
+func TestManagedPool_ShutdownIdleInTransaction(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx := context.Background()
+
+	pool, err := newTestPGInit(t).ManagedPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	if err := pool.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded but got: %v", err)
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT 1"); err == nil {
+		t.Error("expected the connection of the transaction to be closed")
+	}
+
+	_ = tx.Rollback(ctx)
+
+	if acquired := pool.Stat().AcquiredConns(); acquired != 0 {
+		t.Errorf("expected no acquired connection but got %d", acquired)
+	}
+}
@@ -0,0 +1,131 @@
+// Command pgmigrate applies the migrations of a directory to a postgres database.
+//
+// Usage:
+//
+//	pgmigrate [flags] up
+//	pgmigrate [flags] down [steps]
+//	pgmigrate [flags] status
+//
+// The password is read from the PGPASSWORD environment variable.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/migrate"
+	"github.com/rs/zerolog"
+)
+
+var errUsage = errors.New("usage: pgmigrate [flags] up | down [steps] | status")
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		if errors.Is(err, errUsage) {
+			flag.PrintDefaults()
+		}
+
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	host := flag.String("host", "localhost", "database host")
+	port := flag.String("port", "5432", "database port")
+	user := flag.String("user", "postgres", "database user")
+	database := flag.String("database", "postgres", "database name")
+	dir := flag.String("dir", "migrations", "directory containing the migrations")
+	table := flag.String("table", "schema_migrations", "table tracking applied migrations")
+	dryRun := flag.Bool("dry-run", false, "only print the migrations that would run")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		return errUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     *host,
+			Port:     *port,
+			User:     *user,
+			Password: os.Getenv("PGPASSWORD"),
+			Database: *database,
+			MaxConns: 1,
+		},
+		pginit.WithLogger(&logger, ""),
+	)
+	if err != nil {
+		return fmt.Errorf("pginit: %w", err)
+	}
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer pool.Close()
+
+	opts := []migrate.Option{migrate.WithTable(*table), migrate.WithLogger(&logger)}
+	if *dryRun {
+		opts = append(opts, migrate.WithDryRun())
+	}
+
+	migrator, err := migrate.New(pool, os.DirFS(*dir), opts...)
+	if err != nil {
+		return fmt.Errorf("read migrations: %w", err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		if _, err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("up: %w", err)
+		}
+	case "down":
+		steps := 1
+
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps <= 0 {
+				return errUsage
+			}
+		}
+
+		if _, err := migrator.Down(ctx, steps); err != nil {
+			return fmt.Errorf("down: %w", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("status: %w", err)
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+
+			switch {
+			case status.Drifted:
+				state = "drifted"
+			case status.Applied:
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(os.Stdout, "%d\t%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return errUsage
+	}
+
+	return nil
+}
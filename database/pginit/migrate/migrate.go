@@ -0,0 +1,453 @@
+// Package migrate applies versioned SQL migrations with a pool created by pginit.
+//
+// Migrations are read from an fs.FS, so they can be embedded in the binary,
+// and must be named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// version being a positive integer. Applied versions are tracked in a table
+// and a Postgres advisory lock makes sure only one replica of a service migrates at a time.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultTable = "schema_migrations"
+
+	base10    = 10
+	bitSize64 = 64
+)
+
+var (
+	ErrInvalidFilename  = errors.New("invalid migration filename")
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrMissingUp        = errors.New("missing up migration")
+	ErrMissingDown      = errors.New("missing down migration")
+	ErrChecksumDrift    = errors.New("applied migration checksum drift")
+	ErrUnknownVersion   = errors.New("applied migration unknown")
+)
+
+var filenameRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned SQL migration.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of Up only: the down migration of an applied version
+	// can be fixed without drift, since it did not shape the schema.
+	Checksum string
+}
+
+// Status is the state of a migration in the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Drifted is true when the up migration file changed after being applied.
+	Drifted bool
+}
+
+// Option configures Migrator behaviour.
+type Option func(*Migrator)
+
+// Migrator applies migrations to the database.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	table      string
+	lockKey    int64
+	dryRun     bool
+	logger     *zerolog.Logger
+}
+
+// New reads the migrations from the root of fsys and returns a Migrator applying them with pool.
+func New(pool *pgxpool.Pool, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	migrations, err := Read(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{
+		pool:       pool,
+		migrations: migrations,
+		table:      defaultTable,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.lockKey == 0 {
+		m.lockKey = lockKeyFromTable(m.table)
+	}
+
+	return m, nil
+}
+
+// WithTable set the table tracking applied versions, default schema_migrations.
+// It can be qualified with a schema, e.g. myschema.migrations.
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLockKey set the advisory lock key, by default it is derived from the table name.
+func WithLockKey(key int64) Option {
+	return func(m *Migrator) {
+		m.lockKey = key
+	}
+}
+
+// WithDryRun makes Up and Down only report and log the migrations they would run.
+func WithDryRun() Option {
+	return func(m *Migrator) {
+		m.dryRun = true
+	}
+}
+
+// WithLogger logs each migration run.
+func WithLogger(logger *zerolog.Logger) Option {
+	return func(m *Migrator) {
+		m.logger = logger
+	}
+}
+
+// Read parses the migrations at the root of fsys and returns them sorted by version.
+func Read(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		match := filenameRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilename, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], base10, bitSize64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilename, entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read file %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+
+		switch match[3] {
+		case "up":
+			mig.Up = string(content)
+		case "down":
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingUp, mig.Version, mig.Name)
+		}
+
+		sum := sha256.Sum256([]byte(mig.Up))
+		mig.Checksum = hex.EncodeToString(sum[:])
+
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns them.
+// It refuses to run if an applied migration has drifted or is unknown.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+
+			if err := m.run(ctx, conn, status.Migration, true); err != nil {
+				return err
+			}
+
+			applied = append(applied, status.Migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns them, latest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+			if !statuses[i].Applied {
+				continue
+			}
+
+			if statuses[i].Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrMissingDown, statuses[i].Version, statuses[i].Name)
+			}
+
+			if err := m.run(ctx, conn, statuses[i].Migration, false); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, statuses[i].Migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns every known migration with its state in the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.pool.Acquire(pginit.WithoutTenant(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("acquire: %w", err)
+	}
+	defer conn.Release()
+
+	statuses, _, err := m.readStatuses(ctx, conn)
+
+	return statuses, err
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(pginit.WithoutTenant(ctx))
+	if err != nil {
+		return fmt.Errorf("acquire: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", m.lockKey); err != nil {
+		return fmt.Errorf("advisory lock: %w", err)
+	}
+
+	defer func() {
+		// the lock must be released even if ctx is done, or the connection goes back locked to the pool
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", m.lockKey); err != nil {
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if !m.dryRun {
+		if _, err := conn.Exec(ctx, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (
+				version bigint PRIMARY KEY,
+				name text NOT NULL,
+				checksum text NOT NULL,
+				applied_at timestamptz NOT NULL DEFAULT now()
+			)`,
+			m.tableIdentifier(),
+		)); err != nil {
+			return fmt.Errorf("create table: %w", err)
+		}
+	}
+
+	return fn(conn)
+}
+
+// status returns the statuses and fails on drifted or unknown applied migrations.
+func (m *Migrator) status(ctx context.Context, conn *pgxpool.Conn) ([]Status, error) {
+	statuses, unknown, err := m.readStatuses(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownVersion, unknown)
+	}
+
+	for _, status := range statuses {
+		if status.Drifted {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumDrift, status.Version, status.Name)
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) readStatuses(ctx context.Context, conn *pgxpool.Conn) ([]Status, []int64, error) {
+	type appliedMigration struct {
+		checksum  string
+		appliedAt time.Time
+	}
+
+	applied := map[int64]appliedMigration{}
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", m.tableIdentifier()).Scan(&exists); err != nil {
+		return nil, nil, fmt.Errorf("table exists: %w", err)
+	}
+
+	if exists {
+		rows, err := conn.Query(ctx, fmt.Sprintf("SELECT version, checksum, applied_at FROM %s", m.tableIdentifier()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("applied versions: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				version int64
+				app     appliedMigration
+			)
+
+			if err := rows.Scan(&version, &app.checksum, &app.appliedAt); err != nil {
+				return nil, nil, fmt.Errorf("scan applied version: %w", err)
+			}
+
+			applied[version] = app
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("applied versions: %w", err)
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, mig := range m.migrations {
+		status := Status{Migration: mig}
+
+		if app, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = app.appliedAt
+			status.Drifted = app.checksum != mig.Checksum
+
+			delete(applied, mig.Version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	unknown := make([]int64, 0, len(applied))
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+
+	return statuses, unknown, nil
+}
+
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, mig Migration, up bool) error {
+	direction, sql := "up", mig.Up
+	if !up {
+		direction, sql = "down", mig.Down
+	}
+
+	if m.logger != nil {
+		m.logger.Info().
+			Int64("version", mig.Version).
+			Str("name", mig.Name).
+			Str("direction", direction).
+			Bool("dry_run", m.dryRun).
+			Msg("migrate: running migration")
+	}
+
+	if m.dryRun {
+		return nil
+	}
+
+	err := conn.BeginTxFunc(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+
+		if up {
+			if _, err := tx.Exec(
+				ctx,
+				fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)", m.tableIdentifier()),
+				mig.Version, mig.Name, mig.Checksum,
+			); err != nil {
+				return fmt.Errorf("insert version: %w", err)
+			}
+
+			return nil
+		}
+
+		if _, err := tx.Exec(
+			ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.tableIdentifier()),
+			mig.Version,
+		); err != nil {
+			return fmt.Errorf("delete version: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) tableIdentifier() string {
+	return pgx.Identifier(strings.Split(m.table, ".")).Sanitize()
+}
+
+func lockKeyFromTable(table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("pginit/migrate:" + table))
+
+	return int64(h.Sum64())
+}
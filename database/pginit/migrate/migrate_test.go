@@ -0,0 +1,231 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/monacohq/golang-common/database/pginit/migrate"
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+func testMigrations(prefix string) fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_accounts.up.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE " + prefix + "_accounts(id uuid PRIMARY KEY)"),
+		},
+		"0001_create_accounts.down.sql": &fstest.MapFile{
+			Data: []byte("DROP TABLE " + prefix + "_accounts"),
+		},
+		"0002_add_balance.up.sql": &fstest.MapFile{
+			Data: []byte("ALTER TABLE " + prefix + "_accounts ADD COLUMN balance numeric NOT NULL DEFAULT 0"),
+		},
+		"0002_add_balance.down.sql": &fstest.MapFile{
+			Data: []byte("ALTER TABLE " + prefix + "_accounts DROP COLUMN balance"),
+		},
+		"README.md": &fstest.MapFile{Data: []byte("ignored")},
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fsys        fstest.MapFS
+		expectErr   error
+		expectCount int
+	}{
+		{
+			name:        "valid migrations sorted by version",
+			fsys:        testMigrations("read"),
+			expectCount: 2,
+		},
+		{
+			name:      "invalid filename",
+			fsys:      fstest.MapFS{"create_accounts.sql": &fstest.MapFile{Data: []byte("SELECT 1")}},
+			expectErr: migrate.ErrInvalidFilename,
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"1_a.up.sql": &fstest.MapFile{Data: []byte("SELECT 1")},
+				"1_b.up.sql": &fstest.MapFile{Data: []byte("SELECT 1")},
+			},
+			expectErr: migrate.ErrDuplicateVersion,
+		},
+		{
+			name:      "missing up",
+			fsys:      fstest.MapFS{"1_a.down.sql": &fstest.MapFile{Data: []byte("SELECT 1")}},
+			expectErr: migrate.ErrMissingUp,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			migrations, err := migrate.Read(tt.fsys)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected (%v) but got (%v)", tt.expectErr, err)
+			}
+
+			if len(migrations) != tt.expectCount {
+				t.Fatalf("expected %d migrations but got %d", tt.expectCount, len(migrations))
+			}
+
+			for i := 1; i < len(migrations); i++ {
+				if migrations[i-1].Version >= migrations[i].Version {
+					t.Errorf("expected migrations sorted by version but got %+v", migrations)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := pgtest.Pool(t)
+
+	migrator, err := migrate.New(pool, testMigrations("updown"), migrate.WithTable("updown_migrations"))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("expected 2 migrations applied but got %d: %v", len(applied), err)
+	}
+
+	if _, err := pool.Exec(ctx, "INSERT INTO updown_accounts(id, balance) VALUES(gen_random_uuid(), 10.5)"); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected no migration applied but got %d: %v", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("expected migration 2 reverted but got %+v: %v", reverted, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("expected only migration 1 applied but got %+v", statuses)
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := pgtest.Pool(t)
+
+	migrator, err := migrate.New(pool, testMigrations("dryrun"), migrate.WithTable("dryrun_migrations"), migrate.WithDryRun())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	planned, err := migrator.Up(ctx)
+	if err != nil || len(planned) != 2 {
+		t.Fatalf("expected 2 migrations planned but got %d: %v", len(planned), err)
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, "SELECT to_regclass('dryrun_accounts') IS NOT NULL").Scan(&exists); err != nil || exists {
+		t.Errorf("expected dry run not to create table but got: %t, %v", exists, err)
+	}
+}
+
+func TestMigrator_ChecksumDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := pgtest.Pool(t)
+	fsys := testMigrations("drift")
+
+	migrator, err := migrate.New(pool, fsys, migrate.WithTable("drift_migrations"))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	fsys["0002_add_balance.up.sql"] = &fstest.MapFile{
+		Data: []byte("ALTER TABLE drift_accounts ADD COLUMN balance numeric"),
+	}
+
+	migrator, err = migrate.New(pool, fsys, migrate.WithTable("drift_migrations"))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); !errors.Is(err, migrate.ErrChecksumDrift) {
+		t.Errorf("expected (%v) but got (%v)", migrate.ErrChecksumDrift, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil || !statuses[1].Drifted {
+		t.Errorf("expected migration 2 drifted but got %+v: %v", statuses, err)
+	}
+}
+
+func TestMigrator_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := pgtest.Pool(t)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			migrator, err := migrate.New(pool, testMigrations("concurrent"), migrate.WithTable("concurrent_migrations"))
+			if err != nil {
+				t.Errorf("expected no error but got: %v", err)
+
+				return
+			}
+
+			applied, err := migrator.Up(ctx)
+			if err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if total != 2 {
+		t.Errorf("expected migrations applied once but got %d", total)
+	}
+}
@@ -0,0 +1,381 @@
+package pginit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgtype"
+)
+
+var (
+	errUndefinedRange = errors.New("cannot encode status undefined")
+	errRangeBound     = errors.New("invalid range bound")
+	errRangeConvert   = errors.New("cannot convert to numrange")
+)
+
+// numrange binary flags, see pgtype.ParseUntypedBinaryRange.
+const (
+	rangeEmpty          = 0x01
+	rangeLowerInclusive = 0x02
+	rangeUpperInclusive = 0x04
+	rangeLowerUnbounded = 0x08
+	rangeUpperUnbounded = 0x10
+)
+
+// Numrange is a numrange of the decimal type of the library selected by WithDecimalType,
+// e.g. Numrange[decimal.Big] with EricLagergren().
+// Lower and Upper are only set when LowerType and UpperType are pgtype.Inclusive or pgtype.Exclusive,
+// both bound types are pgtype.Empty for the empty range.
+type Numrange[T any] struct {
+	Lower     T
+	Upper     T
+	LowerType pgtype.BoundType
+	UpperType pgtype.BoundType
+	Status    pgtype.Status
+}
+
+func (src Numrange[T]) numrange() (lower, upper interface{}, lowerType, upperType pgtype.BoundType, status pgtype.Status) {
+	return src.Lower, src.Upper, src.LowerType, src.UpperType, src.Status
+}
+
+func (dst *Numrange[T]) assignNumrange(src *numrangeType) error {
+	*dst = Numrange[T]{LowerType: src.lowerType, UpperType: src.upperType, Status: src.status}
+
+	if src.lower != nil {
+		if err := src.lower.AssignTo(&dst.Lower); err != nil {
+			return fmt.Errorf("assign lower: %w", err)
+		}
+	}
+
+	if src.upper != nil {
+		if err := src.upper.AssignTo(&dst.Upper); err != nil {
+			return fmt.Errorf("assign upper: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// numrangeType transcodes numrange with bounds of the registered decimal type, it is registered
+// by WithDecimalType and converts from and to Numrange.
+type numrangeType struct {
+	lower     pgtype.ValueTranscoder
+	upper     pgtype.ValueTranscoder
+	lowerType pgtype.BoundType
+	upperType pgtype.BoundType
+	status    pgtype.Status
+
+	newElement func() pgtype.ValueTranscoder
+}
+
+func newNumrangeType(newElement func() pgtype.ValueTranscoder) *numrangeType {
+	return &numrangeType{newElement: newElement}
+}
+
+// NewTypeValue implements pgtype.TypeValue.
+func (src *numrangeType) NewTypeValue() pgtype.Value {
+	return &numrangeType{newElement: src.newElement}
+}
+
+// TypeName implements pgtype.TypeValue.
+func (src *numrangeType) TypeName() string {
+	return "numrange"
+}
+
+func (dst *numrangeType) reset(lowerType, upperType pgtype.BoundType, status pgtype.Status) {
+	*dst = numrangeType{lowerType: lowerType, upperType: upperType, status: status, newElement: dst.newElement}
+}
+
+// hasValue reports whether a bound of type boundType has a value.
+func hasValue(boundType pgtype.BoundType) bool {
+	return boundType == pgtype.Inclusive || boundType == pgtype.Exclusive
+}
+
+func (dst *numrangeType) Set(src interface{}) error {
+	if src == nil {
+		dst.reset(0, 0, pgtype.Null)
+
+		return nil
+	}
+
+	switch value := src.(type) {
+	case interface {
+		numrange() (lower, upper interface{}, lowerType, upperType pgtype.BoundType, status pgtype.Status)
+	}:
+		lower, upper, lowerType, upperType, status := value.numrange()
+		dst.reset(lowerType, upperType, status)
+
+		if status != pgtype.Present {
+			return nil
+		}
+
+		if hasValue(lowerType) {
+			dst.lower = dst.newElement()
+			if err := dst.lower.Set(lower); err != nil {
+				return fmt.Errorf("set lower: %w", err)
+			}
+		}
+
+		if hasValue(upperType) {
+			dst.upper = dst.newElement()
+			if err := dst.upper.Set(upper); err != nil {
+				return fmt.Errorf("set upper: %w", err)
+			}
+		}
+
+		return nil
+	case string:
+		return dst.DecodeText(nil, []byte(value))
+	}
+
+	if refVal := reflect.ValueOf(src); refVal.Kind() == reflect.Ptr {
+		if refVal.IsNil() {
+			dst.reset(0, 0, pgtype.Null)
+
+			return nil
+		}
+
+		return dst.Set(refVal.Elem().Interface())
+	}
+
+	return fmt.Errorf("%w: %T", errRangeConvert, src)
+}
+
+func (dst *numrangeType) Get() interface{} {
+	switch dst.status {
+	case pgtype.Present:
+		return dst
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.status
+	}
+}
+
+func (src *numrangeType) AssignTo(dst interface{}) error {
+	if src.status == pgtype.Undefined {
+		return fmt.Errorf("AssignTo: %w", errUndefinedRange)
+	}
+
+	if value, ok := dst.(interface{ assignNumrange(*numrangeType) error }); ok {
+		return value.assignNumrange(src)
+	}
+
+	if src.status == pgtype.Null {
+		if err := pgtype.NullAssignTo(dst); err != nil {
+			return fmt.Errorf("assign numrange: %w", err)
+		}
+
+		return nil
+	}
+
+	if nextDst, retry := pgtype.GetAssignToDstType(dst); retry {
+		return src.AssignTo(nextDst)
+	}
+
+	return fmt.Errorf("%w: cannot assign to %T", errRangeConvert, dst)
+}
+
+func (dst *numrangeType) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		dst.reset(0, 0, pgtype.Null)
+
+		return nil
+	}
+
+	utr, err := pgtype.ParseUntypedTextRange(string(src))
+	if err != nil {
+		return fmt.Errorf("decode numrange: %w", err)
+	}
+
+	dst.reset(utr.LowerType, utr.UpperType, pgtype.Present)
+
+	if hasValue(utr.LowerType) {
+		dst.lower = dst.newElement()
+		if err := dst.lower.DecodeText(ci, []byte(utr.Lower)); err != nil {
+			return fmt.Errorf("decode lower: %w", err)
+		}
+	}
+
+	if hasValue(utr.UpperType) {
+		dst.upper = dst.newElement()
+		if err := dst.upper.DecodeText(ci, []byte(utr.Upper)); err != nil {
+			return fmt.Errorf("decode upper: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (dst *numrangeType) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		dst.reset(0, 0, pgtype.Null)
+
+		return nil
+	}
+
+	ubr, err := pgtype.ParseUntypedBinaryRange(src)
+	if err != nil {
+		return fmt.Errorf("decode numrange: %w", err)
+	}
+
+	dst.reset(ubr.LowerType, ubr.UpperType, pgtype.Present)
+
+	if hasValue(ubr.LowerType) {
+		dst.lower = dst.newElement()
+		if err := dst.lower.DecodeBinary(ci, ubr.Lower); err != nil {
+			return fmt.Errorf("decode lower: %w", err)
+		}
+	}
+
+	if hasValue(ubr.UpperType) {
+		dst.upper = dst.newElement()
+		if err := dst.upper.DecodeBinary(ci, ubr.Upper); err != nil {
+			return fmt.Errorf("decode upper: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// nolint: cyclop // bound types on both sides
+func (src *numrangeType) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errUndefinedRange
+	}
+
+	switch src.lowerType {
+	case pgtype.Exclusive, pgtype.Unbounded:
+		buf = append(buf, '(')
+	case pgtype.Inclusive:
+		buf = append(buf, '[')
+	case pgtype.Empty:
+		return append(buf, "empty"...), nil
+	default:
+		return nil, fmt.Errorf("%w: lower %v", errRangeBound, src.lowerType)
+	}
+
+	var err error
+
+	if src.lowerType != pgtype.Unbounded {
+		if buf, err = encodeBoundText(ci, src.lower, buf); err != nil {
+			return nil, fmt.Errorf("encode lower: %w", err)
+		}
+	}
+
+	buf = append(buf, ',')
+
+	if src.upperType != pgtype.Unbounded {
+		if buf, err = encodeBoundText(ci, src.upper, buf); err != nil {
+			return nil, fmt.Errorf("encode upper: %w", err)
+		}
+	}
+
+	switch src.upperType {
+	case pgtype.Exclusive, pgtype.Unbounded:
+		buf = append(buf, ')')
+	case pgtype.Inclusive:
+		buf = append(buf, ']')
+	default:
+		return nil, fmt.Errorf("%w: upper %v", errRangeBound, src.upperType)
+	}
+
+	return buf, nil
+}
+
+func encodeBoundText(ci *pgtype.ConnInfo, bound pgtype.ValueTranscoder, buf []byte) ([]byte, error) {
+	if bound == nil {
+		return nil, fmt.Errorf("%w: null bound", errRangeBound)
+	}
+
+	buf, err := bound.EncodeText(ci, buf)
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	if buf == nil {
+		return nil, fmt.Errorf("%w: null bound", errRangeBound)
+	}
+
+	return buf, nil
+}
+
+// nolint: cyclop // bound types on both sides
+func (src *numrangeType) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errUndefinedRange
+	}
+
+	var flags byte
+
+	switch src.lowerType {
+	case pgtype.Inclusive:
+		flags |= rangeLowerInclusive
+	case pgtype.Unbounded:
+		flags |= rangeLowerUnbounded
+	case pgtype.Exclusive:
+	case pgtype.Empty:
+		return append(buf, rangeEmpty), nil
+	default:
+		return nil, fmt.Errorf("%w: lower %v", errRangeBound, src.lowerType)
+	}
+
+	switch src.upperType {
+	case pgtype.Inclusive:
+		flags |= rangeUpperInclusive
+	case pgtype.Unbounded:
+		flags |= rangeUpperUnbounded
+	case pgtype.Exclusive:
+	default:
+		return nil, fmt.Errorf("%w: upper %v", errRangeBound, src.upperType)
+	}
+
+	buf = append(buf, flags)
+
+	var err error
+
+	if src.lowerType != pgtype.Unbounded {
+		if buf, err = encodeBoundBinary(ci, src.lower, buf); err != nil {
+			return nil, fmt.Errorf("encode lower: %w", err)
+		}
+	}
+
+	if src.upperType != pgtype.Unbounded {
+		if buf, err = encodeBoundBinary(ci, src.upper, buf); err != nil {
+			return nil, fmt.Errorf("encode upper: %w", err)
+		}
+	}
+
+	return buf, nil
+}
+
+// encodeBoundBinary appends the bound prefixed by its length.
+func encodeBoundBinary(ci *pgtype.ConnInfo, bound pgtype.ValueTranscoder, buf []byte) ([]byte, error) {
+	if bound == nil {
+		return nil, fmt.Errorf("%w: null bound", errRangeBound)
+	}
+
+	sp := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+
+	buf, err := bound.EncodeBinary(ci, buf)
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	if buf == nil {
+		return nil, fmt.Errorf("%w: null bound", errRangeBound)
+	}
+
+	binary.BigEndian.PutUint32(buf[sp:], uint32(len(buf)-sp-4)) // nolint: gomnd // length prefix
+
+	return buf, nil
+}
@@ -0,0 +1,200 @@
+// Package outbox publishes events consistently with database writes (transactional outbox).
+//
+// Insert stores an event in the outbox table within the transaction of the caller,
+// so that the event exists if and only if the transaction commits. A Relay then leases
+// the pending events with FOR UPDATE SKIP LOCKED, so that several replicas can run one,
+// and hands them to a Publisher, e.g. a Kafka or SQS producer, outside of any transaction.
+// Failed events are retried with backoff and dead-lettered after too many attempts.
+//
+// The OpenTelemetry trace context of Insert is stored alongside each event,
+// so that the span of its publication continues the trace of the request that wrote it.
+//
+//	ob := outbox.New()
+//
+//	err := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+//		if _, err := tx.Exec(ctx, "INSERT INTO orders ..."); err != nil {
+//			return err
+//		}
+//
+//		_, err := ob.Insert(ctx, tx, outbox.Message{Topic: "orders.created", Key: orderID, Payload: payload})
+//
+//		return err
+//	})
+//
+//	go ob.NewRelay(pool, publisher).Run(ctx)
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/monacohq/golang-common/database/pginit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const defaultTable = "outbox_events"
+
+// ErrMissingTopic is returned by Insert for a Message without Topic.
+var ErrMissingTopic = errors.New("outbox: missing topic")
+
+// Status is the state of an event in the outbox table.
+type Status string
+
+const (
+	// StatusPending events are waiting to be published, or retried.
+	StatusPending Status = "pending"
+	// StatusPublished events were handed to the Publisher successfully.
+	StatusPublished Status = "published"
+	// StatusDead events failed to be published too many times and are not retried anymore.
+	StatusDead Status = "dead"
+)
+
+// Message is an event to publish.
+type Message struct {
+	// Topic is where the event is published, it is required.
+	Topic string
+	// Key is passed to the Publisher, e.g. as the partition key of a Kafka message.
+	Key     string
+	Payload []byte
+	Headers map[string]string
+}
+
+// Option configures an Outbox.
+type Option func(*Outbox)
+
+// WithTable set the outbox table, default outbox_events.
+// It can be qualified with a schema, e.g. myschema.outbox.
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// WithPropagator set the propagator storing the trace context of the events,
+// default the global one of otel.SetTextMapPropagator.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *Outbox) {
+		o.propagator = propagator
+	}
+}
+
+// Outbox writes events to the outbox table.
+type Outbox struct {
+	table      string
+	propagator propagation.TextMapPropagator
+}
+
+// New returns an Outbox.
+func New(opts ...Option) *Outbox {
+	o := &Outbox{
+		table:      defaultTable,
+		propagator: otel.GetTextMapPropagator(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// CreateTable creates the outbox table if it does not exist, services using migrations
+// should rather copy its definition into one of them.
+func (o *Outbox) CreateTable(ctx context.Context, db pginit.Execer) error {
+	if _, err := db.Exec(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (
+			id bigserial PRIMARY KEY,
+			topic text NOT NULL,
+			key text NOT NULL DEFAULT '',
+			payload bytea,
+			headers jsonb,
+			trace_context jsonb,
+			status text NOT NULL DEFAULT 'pending',
+			attempts integer NOT NULL DEFAULT 0,
+			last_error text,
+			available_at timestamptz NOT NULL DEFAULT now(),
+			created_at timestamptz NOT NULL DEFAULT now(),
+			published_at timestamptz
+		)`,
+		o.tableIdentifier(),
+	)); err != nil {
+		return fmt.Errorf("create table: %w", err)
+	}
+
+	parts := strings.Split(o.table, ".")
+
+	if _, err := db.Exec(ctx, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s ON %s (available_at, id) WHERE status = 'pending'",
+		pgx.Identifier{parts[len(parts)-1] + "_pending_idx"}.Sanitize(),
+		o.tableIdentifier(),
+	)); err != nil {
+		return fmt.Errorf("create index: %w", err)
+	}
+
+	return nil
+}
+
+// Insert stores msg in the outbox table within tx, with the trace context of ctx, and returns its id.
+// The event is published by a Relay once tx commits.
+func (o *Outbox) Insert(ctx context.Context, tx pgx.Tx, msg Message) (int64, error) {
+	if msg.Topic == "" {
+		return 0, ErrMissingTopic
+	}
+
+	traceContext := propagation.MapCarrier{}
+	o.propagator.Inject(ctx, traceContext)
+
+	var id int64
+
+	if err := tx.QueryRow(ctx,
+		fmt.Sprintf(
+			"INSERT INTO %s (topic, key, payload, headers, trace_context) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			o.tableIdentifier(),
+		),
+		msg.Topic, msg.Key, msg.Payload, msg.Headers, map[string]string(traceContext),
+	).Scan(&id); err != nil {
+		return 0, fmt.Errorf("insert event: %w", err)
+	}
+
+	return id, nil
+}
+
+// Requeue makes dead events pending again, e.g. once the cause of their failures is fixed,
+// with their attempts reset. It returns the number of requeued events.
+func (o *Outbox) Requeue(ctx context.Context, db pginit.Execer, ids ...int64) (int64, error) {
+	tag, err := db.Exec(ctx,
+		fmt.Sprintf(
+			`UPDATE %s SET status = $1, attempts = 0, available_at = now()
+			WHERE id = ANY($2) AND status = $3`,
+			o.tableIdentifier(),
+		),
+		StatusPending, ids, StatusDead,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("requeue events: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (o *Outbox) tableIdentifier() string {
+	return pgx.Identifier(strings.Split(o.table, ".")).Sanitize()
+}
+
+// Event is an event claimed by a Relay.
+type Event struct {
+	Message
+	ID int64
+	// Attempts is the number of previous failed attempts to publish the event.
+	Attempts  int
+	CreatedAt time.Time
+
+	traceContext map[string]string
+	// leasedUntil is the available_at set by the claim, it identifies the lease in the outcome updates.
+	leasedUntil time.Time
+}
@@ -0,0 +1,302 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit/outbox"
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+func newOutbox(t *testing.T) (*outbox.Outbox, *pgxpool.Pool) {
+	t.Helper()
+
+	pool := pgtest.Pool(t)
+	ob := outbox.New(outbox.WithPropagator(propagation.TraceContext{}))
+
+	if err := ob.CreateTable(context.Background(), pool); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	return ob, pool
+}
+
+func insert(ctx context.Context, t *testing.T, ob *outbox.Outbox, pool *pgxpool.Pool, msg outbox.Message, commit bool) {
+	t.Helper()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := ob.Insert(ctx, tx, msg); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if commit {
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+}
+
+func status(t *testing.T, pool *pgxpool.Pool) (outbox.Status, int, string) {
+	t.Helper()
+
+	var (
+		st        outbox.Status
+		attempts  int
+		lastError *string
+	)
+
+	if err := pool.QueryRow(context.Background(),
+		"SELECT status, attempts, last_error FROM outbox_events",
+	).Scan(&st, &attempts, &lastError); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if lastError == nil {
+		return st, attempts, ""
+	}
+
+	return st, attempts, *lastError
+}
+
+func TestInsert_MissingTopic(t *testing.T) {
+	t.Parallel()
+
+	if _, err := outbox.New().Insert(context.Background(), nil, outbox.Message{}); !errors.Is(err, outbox.ErrMissingTopic) {
+		t.Errorf("expected ErrMissingTopic but got: %v", err)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Parallel()
+
+	ob, pool := newOutbox(t)
+
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	insert(ctx, t, ob, pool, outbox.Message{
+		Topic: "orders.created", Key: "42", Payload: []byte(`{"id":42}`), Headers: map[string]string{"version": "1"},
+	}, true)
+	// rolled back, so never published
+	insert(ctx, t, ob, pool, outbox.Message{Topic: "orders.created", Key: "43"}, false)
+
+	var published []outbox.Event
+
+	relay := ob.NewRelay(pool, outbox.PublisherFunc(func(ctx context.Context, event outbox.Event) error {
+		if got := trace.SpanContextFromContext(ctx).TraceID(); got != traceID {
+			t.Errorf("expected trace %s but got %s", traceID, got)
+		}
+
+		published = append(published, event)
+
+		return nil
+	}))
+
+	n, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if n != 1 || len(published) != 1 {
+		t.Fatalf("expected 1 event published but got %d", len(published))
+	}
+
+	if event := published[0]; event.Topic != "orders.created" || event.Key != "42" ||
+		string(event.Payload) != `{"id":42}` || event.Headers["version"] != "1" || event.Attempts != 0 {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if st, _, _ := status(t, pool); st != outbox.StatusPublished {
+		t.Errorf("expected %s but got %s", outbox.StatusPublished, st)
+	}
+
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 0 {
+		t.Errorf("expected nothing left to relay but got %d, %v", n, err)
+	}
+}
+
+func TestRelay_RetryAndDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ob, pool := newOutbox(t)
+
+	insert(ctx, t, ob, pool, outbox.Message{Topic: "orders.created"}, true)
+
+	relay := ob.NewRelay(pool, outbox.PublisherFunc(func(context.Context, outbox.Event) error {
+		return errors.New("broker unavailable")
+	}), outbox.WithMaxAttempts(2), outbox.WithBackoff(10*time.Millisecond, 10*time.Millisecond))
+
+	if _, err := relay.RelayBatch(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if st, attempts, lastError := status(t, pool); st != outbox.StatusPending || attempts != 1 || lastError == "" {
+		t.Errorf("expected a pending event with 1 attempt but got %s, %d, %q", st, attempts, lastError)
+	}
+
+	// not available again before the backoff
+	if n, err := relay.RelayBatch(ctx); err != nil || n != 0 {
+		t.Errorf("expected no event to retry yet but got %d, %v", n, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := relay.RelayBatch(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if st, attempts, _ := status(t, pool); st != outbox.StatusDead || attempts != 2 {
+		t.Errorf("expected a dead event with 2 attempts but got %s, %d", st, attempts)
+	}
+
+	var id int64
+	if err := pool.QueryRow(ctx, "SELECT id FROM outbox_events").Scan(&id); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if n, err := ob.Requeue(ctx, pool, id); err != nil || n != 1 {
+		t.Fatalf("expected 1 event requeued but got %d, %v", n, err)
+	}
+
+	if st, attempts, _ := status(t, pool); st != outbox.StatusPending || attempts != 0 {
+		t.Errorf("expected a pending event without attempts but got %s, %d", st, attempts)
+	}
+}
+
+func TestRelay_SkipLocked(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ob, pool := newOutbox(t)
+
+	insert(ctx, t, ob, pool, outbox.Message{Topic: "orders.created"}, true)
+
+	// another relay holding the event
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "SELECT id FROM outbox_events FOR UPDATE"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	relay := ob.NewRelay(pool, outbox.PublisherFunc(func(context.Context, outbox.Event) error {
+		t.Error("expected the locked event to be skipped")
+
+		return nil
+	}))
+
+	if n, err := relay.RelayBatch(ctx); err != nil || n != 0 {
+		t.Errorf("expected no event claimed but got %d, %v", n, err)
+	}
+}
+
+func TestRelay_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	ob, pool := newOutbox(t)
+
+	for i := 0; i < 3; i++ {
+		insert(context.Background(), t, ob, pool, outbox.Message{Topic: "orders.created"}, true)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var published int
+
+	relay := ob.NewRelay(pool, outbox.PublisherFunc(func(ctx context.Context, event outbox.Event) error {
+		if published == 1 {
+			// the claimed events are leased to this relay
+			other := ob.NewRelay(pool, outbox.PublisherFunc(func(context.Context, outbox.Event) error {
+				t.Error("expected the leased events to be skipped")
+
+				return nil
+			}))
+
+			if n, err := other.RelayBatch(context.Background()); err != nil || n != 0 {
+				t.Errorf("expected no event claimed but got %d, %v", n, err)
+			}
+
+			cancel()
+
+			return ctx.Err()
+		}
+
+		published++
+
+		return nil
+	}))
+
+	if n, err := relay.RelayBatch(ctx); !errors.Is(err, context.Canceled) || n != 1 {
+		t.Fatalf("expected 1 event relayed and context.Canceled but got %d, %v", n, err)
+	}
+
+	var statuses []outbox.Status
+	if err := pool.QueryRow(context.Background(),
+		"SELECT array_agg(status ORDER BY id) FROM outbox_events WHERE available_at <= now() OR status = 'published'",
+	).Scan(&statuses); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	// the outcome of the first event is kept, the others are available again without attempt
+	if len(statuses) != 3 || statuses[0] != outbox.StatusPublished ||
+		statuses[1] != outbox.StatusPending || statuses[2] != outbox.StatusPending {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+}
+
+func TestRelay_LeaseExpired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ob, pool := newOutbox(t)
+
+	insert(ctx, t, ob, pool, outbox.Message{Topic: "orders.created"}, true)
+
+	other := ob.NewRelay(pool, outbox.PublisherFunc(func(context.Context, outbox.Event) error {
+		return nil
+	}))
+
+	slow := ob.NewRelay(pool, outbox.PublisherFunc(func(context.Context, outbox.Event) error {
+		// the lease expires while publishing, another relay publishes the event meanwhile
+		time.Sleep(100 * time.Millisecond)
+
+		if n, err := other.RelayBatch(ctx); err != nil || n != 1 {
+			t.Errorf("expected 1 event relayed by the other relay but got %d, %v", n, err)
+		}
+
+		return errors.New("broker timeout")
+	}), outbox.WithLease(50*time.Millisecond))
+
+	if _, err := slow.RelayBatch(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	// the late failure does not overwrite the publication
+	if st, attempts, _ := status(t, pool); st != outbox.StatusPublished || attempts != 0 {
+		t.Errorf("expected a published event without failed attempts but got %s, %d", st, attempts)
+	}
+}
@@ -0,0 +1,392 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/monacohq/golang-common/database/pginit/outbox"
+
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 10
+	defaultBackoff      = time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultLease        = 5 * time.Minute
+	recordTimeout       = 5 * time.Second
+)
+
+// Publisher publishes the events claimed by a Relay, e.g. to a message broker.
+// Its ctx carries the trace context of the event.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// PublisherFunc is a function implementing Publisher.
+type PublisherFunc func(ctx context.Context, event Event) error
+
+// Publish calls f.
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// RelayOption configures a Relay.
+type RelayOption func(*Relay)
+
+// WithBatchSize set the maximum number of events claimed at once, default 100.
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		if size > 0 {
+			r.batchSize = size
+		}
+	}
+}
+
+// WithPollInterval set how often Run looks for pending events when the outbox is drained, default 1s.
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		if interval > 0 {
+			r.pollInterval = interval
+		}
+	}
+}
+
+// WithMaxAttempts set the number of attempts after which an event is dead-lettered, default 10.
+func WithMaxAttempts(attempts int) RelayOption {
+	return func(r *Relay) {
+		if attempts > 0 {
+			r.maxAttempts = attempts
+		}
+	}
+}
+
+// WithBackoff set the delay before retrying a failed event, doubled at each attempt up to max
+// with jitter, see pginit.Backoff, default 1s up to 1h.
+func WithBackoff(base, max time.Duration) RelayOption {
+	return func(r *Relay) {
+		if base > 0 {
+			r.backoff = base
+		}
+
+		r.maxBackoff = max
+		if r.maxBackoff < r.backoff {
+			r.maxBackoff = r.backoff
+		}
+	}
+}
+
+// WithLease set how long the claimed events are skipped by the other relays, default 5m.
+// It must be longer than publishing a batch: the events of a relay dying are relayed again after it.
+func WithLease(lease time.Duration) RelayOption {
+	return func(r *Relay) {
+		if lease > 0 {
+			r.lease = lease
+		}
+	}
+}
+
+// WithLogger logs the failed attempts and the dead-lettered events.
+func WithLogger(logger *zerolog.Logger) RelayOption {
+	return func(r *Relay) {
+		r.logger = logger
+	}
+}
+
+// WithTracerProvider set the provider of the tracer of the publication spans,
+// default the global one of otel.SetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) RelayOption {
+	return func(r *Relay) {
+		r.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+// Relay hands the pending events of an outbox to a Publisher.
+//
+// Delivery is at least once: an event is published again if the relay fails to mark it published
+// or dies before, so consumers should deduplicate on Event.ID. Events are claimed in id order,
+// but retries and concurrent relays can publish them out of order.
+type Relay struct {
+	outbox    *Outbox
+	db        DB
+	publisher Publisher
+
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	lease        time.Duration
+	logger       *zerolog.Logger
+	tracer       trace.Tracer
+}
+
+// DB runs the statements of a Relay, it is implemented by *pgxpool.Pool and *pgx.Conn.
+type DB interface {
+	pginit.Querier
+	pginit.Execer
+}
+
+// NewRelay returns a Relay publishing the events of o stored in db with publisher.
+func (o *Outbox) NewRelay(db DB, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		outbox:       o,
+		db:           db,
+		publisher:    publisher,
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+		maxAttempts:  defaultMaxAttempts,
+		backoff:      defaultBackoff,
+		maxBackoff:   defaultMaxBackoff,
+		lease:        defaultLease,
+		tracer:       otel.Tracer(instrumentationName),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Run relays the events until ctx is done, it always returns the error of ctx.
+// Errors of the database are logged and retried at the next poll.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayBatch(ctx)
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("relay: %w", ctx.Err())
+		}
+
+		if err != nil && r.logger != nil {
+			r.logger.Error().Err(err).Msg("outbox: relay batch")
+		}
+
+		// the outbox may not be drained yet
+		if err == nil && n == r.batchSize {
+			continue
+		}
+
+		timer := time.NewTimer(r.pollInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("relay: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// RelayBatch claims up to the batch size of pending events, leasing them so that other relays skip them,
+// then publishes them one by one outside of any transaction and records each outcome right away.
+// If it stops early, the claimed events not relayed yet are made available again.
+// It returns the number of relayed events.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	events, err := r.claim(pginit.WithoutTenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		if err := r.relay(ctx, event); err != nil {
+			r.unclaim(events[i:])
+
+			return i, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// claim leases the available pending events in a single statement, pushing their available_at
+// past the lease so that they are relayed again only if this relay dies.
+func (r *Relay) claim(ctx context.Context) ([]Event, error) {
+	rows, err := r.db.Query(ctx,
+		fmt.Sprintf(
+			`UPDATE %[1]s SET available_at = now() + $1::bigint * interval '1 microsecond'
+			WHERE id IN (
+				SELECT id FROM %[1]s
+				WHERE status = $2 AND available_at <= now()
+				ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, topic, key, payload, headers, trace_context, attempts, created_at, available_at`,
+			r.outbox.tableIdentifier(),
+		),
+		r.lease.Microseconds(), StatusPending, r.batchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+
+	for rows.Next() {
+		var event Event
+
+		if err := rows.Scan(
+			&event.ID, &event.Topic, &event.Key, &event.Payload, &event.Headers,
+			&event.traceContext, &event.Attempts, &event.CreatedAt, &event.leasedUntil,
+		); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim events: %w", err)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+
+	return events, nil
+}
+
+// unclaim ends the lease of events, so that they are relayed right away.
+// The events claimed by the same statement share their lease.
+func (r *Relay) unclaim(events []Event) {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+
+	ctx, cancel := recordContext()
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx,
+		fmt.Sprintf(
+			"UPDATE %s SET available_at = now() WHERE id = ANY($1) AND status = $2 AND available_at = $3",
+			r.outbox.tableIdentifier(),
+		),
+		ids, StatusPending, events[0].leasedUntil,
+	); err != nil && r.logger != nil {
+		r.logger.Error().Err(err).Msg("outbox: unclaim events")
+	}
+}
+
+// record updates event with the assignments of set while this relay still holds its lease:
+// once the lease expired, the event may have been claimed and relayed by another relay,
+// whose outcome must not be overwritten. It returns whether event was updated.
+func (r *Relay) record(ctx context.Context, event Event, set string, args ...interface{}) (bool, error) {
+	n := len(args)
+
+	commandTag, err := r.db.Exec(ctx,
+		fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND status = $%d AND available_at = $%d",
+			r.outbox.tableIdentifier(), set, n+1, n+2, n+3),
+		append(args, event.ID, StatusPending, event.leasedUntil)...,
+	)
+	if err != nil {
+		return false, fmt.Errorf("record event: %w", err)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		if r.logger != nil {
+			r.logger.Warn().
+				Int64("event_id", event.ID).
+				Str("topic", event.Topic).
+				Msg("outbox: outcome not recorded, the lease of the event expired")
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// recordContext returns the context recording the outcomes, which must be recorded even when the relay stops.
+func recordContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(pginit.WithoutTenant(context.Background()), recordTimeout)
+}
+
+// relay publishes event and records the outcome.
+func (r *Relay) relay(ctx context.Context, event Event) error {
+	errPublish := r.publish(ctx, event)
+
+	recordCtx, cancel := recordContext()
+	defer cancel()
+
+	if errPublish == nil {
+		if _, err := r.record(recordCtx, event, "status = $1, published_at = now()", StatusPublished); err != nil {
+			return fmt.Errorf("mark event published: %w", err)
+		}
+
+		return nil
+	}
+
+	// the failure is caused by the shutdown, not by the event
+	if ctx.Err() != nil {
+		return fmt.Errorf("publish: %w", ctx.Err())
+	}
+
+	attempts := event.Attempts + 1
+	status := StatusPending
+
+	if attempts >= r.maxAttempts {
+		status = StatusDead
+	}
+
+	updated, err := r.record(recordCtx, event,
+		`status = $1, attempts = $2, last_error = $3,
+		available_at = now() + $4::bigint * interval '1 microsecond'`,
+		status, attempts, errPublish.Error(), pginit.Backoff(r.backoff, r.maxBackoff, attempts-1).Microseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("mark event failed: %w", err)
+	}
+
+	if updated && r.logger != nil {
+		logEvent := r.logger.Warn()
+		msg := "outbox: publish failed, will retry"
+
+		if status == StatusDead {
+			logEvent = r.logger.Error()
+			msg = "outbox: publish failed, event dead-lettered"
+		}
+
+		logEvent.Err(errPublish).
+			Int64("event_id", event.ID).
+			Str("topic", event.Topic).
+			Int("attempts", attempts).
+			Msg(msg)
+	}
+
+	return nil
+}
+
+// publish hands event to the publisher in a span continuing the trace of its insertion.
+func (r *Relay) publish(ctx context.Context, event Event) error {
+	ctx = r.outbox.propagator.Extract(ctx, propagation.MapCarrier(event.traceContext))
+
+	ctx, span := r.tracer.Start(ctx, event.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.Int64("outbox.event_id", event.ID),
+			attribute.String("outbox.topic", event.Topic),
+			attribute.Int("outbox.attempts", event.Attempts),
+		),
+	)
+	defer span.End()
+
+	if err := r.publisher.Publish(ctx, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("publish event %d: %w", event.ID, err)
+	}
+
+	return nil
+}
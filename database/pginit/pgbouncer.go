@@ -0,0 +1,19 @@
+package pginit
+
+// WithPgBouncerMode makes pginit work behind PgBouncer in transaction pooling mode, where consecutive statements
+// of a connection may run on different server connections and prepared statements fail with
+// "prepared statement already exists": queries use the simple protocol and the statement cache is disabled.
+// Arguments are then encoded as text by pgx, the Go types of WithDecimalType and WithUUIDType included,
+// e.g. decimal.Big, []uuid.UUID or Numrange.
+//
+// The features holding session state need a session and must not go through PgBouncer: Listener,
+// Lock and RunLeaderElection, the advisory lock of package migrate, and WithTenant, whose search_path
+// or role would be set on a server connection shared with other clients. New rejects WithTenant
+// with WithPgBouncerMode, the others must use a PGInit connecting to Postgres directly.
+func WithPgBouncerMode() Option {
+	return func(pgi *PGInit) {
+		pgi.pgBouncerMode = true
+		pgi.pgxConf.ConnConfig.PreferSimpleProtocol = true
+		pgi.pgxConf.ConnConfig.BuildStatementCache = nil
+	}
+}
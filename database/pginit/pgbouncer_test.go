@@ -0,0 +1,75 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+// TestWithPgBouncerMode runs against Postgres directly: the simple protocol used behind PgBouncer
+// must still encode and decode the custom types. Running behind a real PgBouncer is not covered.
+func TestWithPgBouncerMode(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pool := newTestPool(t, pginit.WithDecimalType(), pginit.WithUUIDType(), pginit.WithPgBouncerMode())
+
+	if conf := pool.Config().ConnConfig; !conf.PreferSimpleProtocol || conf.BuildStatementCache != nil {
+		t.Fatal("expected the simple protocol without statement cache")
+	}
+
+	id := uuid.Must(uuid.NewV4())
+
+	// the same statement twice, which fails with prepared statements behind PgBouncer
+	for i := 0; i < 2; i++ {
+		var (
+			gotID   uuid.UUID
+			amount  decimal.Big
+			amounts []*decimal.Big
+			ids     []uuid.UUID
+			rng     pginit.Numrange[decimal.Big]
+		)
+
+		if err := pool.QueryRow(ctx, "SELECT $1::uuid, $2::numeric, $3::numeric[], $4::uuid[], $5::numrange",
+			id, decimal.New(15, 1), []*decimal.Big{decimal.New(-2, 0), nil}, []uuid.UUID{id},
+			pginit.Numrange[decimal.Big]{
+				Lower: *decimal.New(1, 0), LowerType: pgtype.Inclusive, UpperType: pgtype.Unbounded, Status: pgtype.Present,
+			},
+		).Scan(&gotID, &amount, &amounts, &ids, &rng); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if gotID != id || amount.Cmp(decimal.New(15, 1)) != 0 {
+			t.Errorf("unexpected uuid %s or numeric %s", gotID, amount.String())
+		}
+
+		if len(amounts) != 2 || amounts[0].Cmp(decimal.New(-2, 0)) != 0 || amounts[1] != nil || len(ids) != 1 || ids[0] != id {
+			t.Errorf("unexpected arrays %v %v", amounts, ids)
+		}
+
+		if rng.LowerType != pgtype.Inclusive || rng.Lower.Cmp(decimal.New(1, 0)) != 0 || rng.UpperType != pgtype.Unbounded {
+			t.Errorf("unexpected range %+v", rng)
+		}
+	}
+}
+
+func TestWithPgBouncerMode_Tenant(t *testing.T) {
+	t.Parallel()
+
+	_, err := pginit.New(&pginit.Config{
+		Host:     "localhost",
+		Port:     "6432",
+		User:     "postgres",
+		Password: "postgres",
+		Database: "datawarehouse",
+	}, pginit.WithTenant("tenant", pginit.TenantSchema), pginit.WithPgBouncerMode())
+	if !errors.Is(err, pginit.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig but got: %v", err)
+	}
+}
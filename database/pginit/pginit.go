@@ -3,9 +3,15 @@
 //
 // default MaxConns = 25
 //
-// default MaxIdleConns = 25
+// default MinConns = 0
+//
+// default MaxIdleConns = MaxConns
 //
 // default MaxLifeTime = 5 minute
 //
+// default MaxConnIdleTime = 30 minute
+//
+// default HealthCheckPeriod = 1 minute
+//
 // default LogLevel = Warn
 package pginit
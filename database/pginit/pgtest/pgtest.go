@@ -0,0 +1,380 @@
+// Package pgtest provides isolated Postgres databases to tests.
+//
+// Main starts a Postgres once per test binary: the server at PG_TEST_URL when it is set,
+// e.g. in CI without Docker, otherwise a postgres container started with dockertest.
+// The migrations given with WithMigrations are applied once to a template database,
+// and each test calling New, Pool, Config or URL gets its own database created from the template
+// and dropped when the test ends. Postgres 13 or later is required.
+//
+//	func TestMain(m *testing.M) {
+//		pgtest.Main(m, pgtest.WithMigrations(migrations))
+//	}
+//
+//	func TestAccounts(t *testing.T) {
+//		pool := pgtest.Pool(t, pginit.WithDecimalType())
+//		...
+//	}
+package pgtest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/migrate"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"go.uber.org/goleak"
+)
+
+// URLEnv is the environment variable of the URL of an already running Postgres, used instead of Docker.
+const URLEnv = "PG_TEST_URL"
+
+const (
+	defaultImageTag = "14"
+	dockerMaxWait   = 120 * time.Second
+	dockerExpire    = 600
+	adminTimeout    = 30 * time.Second
+	createAttempts  = 10
+	createBackoff   = 100 * time.Millisecond
+
+	sqlStateObjectInUse = "55006"
+)
+
+// Option configures Main.
+type Option func(*harness)
+
+// WithMigrations applies the migrations at the root of fsys to the template database, see package migrate.
+func WithMigrations(fsys fs.FS) Option {
+	return func(h *harness) {
+		h.migrations = fsys
+	}
+}
+
+// WithImageTag set the tag of the postgres image started when PG_TEST_URL is not set, default 14.
+func WithImageTag(tag string) Option {
+	return func(h *harness) {
+		h.imageTag = tag
+	}
+}
+
+type harness struct {
+	migrations fs.FS
+	imageTag   string
+
+	admin    *pgx.ConnConfig
+	template string
+	// mu serializes the database creations, Postgres refuses concurrent copies of a template
+	mu sync.Mutex
+	// next numbers the test databases
+	next int64
+}
+
+// current is the harness started by Main.
+var current *harness // nolint: gochecknoglobals // set once by Main
+
+// Main starts Postgres, creates the template database, runs the tests and exits, it is meant to be called by TestMain.
+// With the -leak flag, the tests are only run with the leak detector, like the tests of pginit.
+func Main(m *testing.M, opts ...Option) {
+	leak := flag.Bool("leak", false, "use leak detector")
+	flag.Parse()
+
+	if *leak {
+		goleak.VerifyTestMain(m)
+
+		return
+	}
+
+	h := &harness{imageTag: defaultImageTag}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	databaseURL, purge, err := h.start()
+	if err != nil {
+		log.Fatalf("pgtest: %s", err)
+	}
+
+	if err := h.setup(databaseURL); err != nil {
+		purge()
+		log.Fatalf("pgtest: %s", err)
+	}
+
+	current = h
+
+	code := m.Run()
+
+	h.dropDatabase(h.template)
+	// You can't defer this because os.Exit doesn't care for defer
+	purge()
+
+	os.Exit(code)
+}
+
+// start returns the URL of PG_TEST_URL, or of a postgres container, and the func removing the container.
+func (h *harness) start() (string, func(), error) {
+	if databaseURL := os.Getenv(URLEnv); databaseURL != "" {
+		return databaseURL, func() {}, nil
+	}
+
+	// uses a sensible default on windows (tcp/http) and linux/osx (socket)
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return "", nil, fmt.Errorf("connect to docker, set %s to use a running postgres: %w", URLEnv, err)
+	}
+
+	// pulls an image, creates a container based on it and runs it
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        h.imageTag,
+		Env: []string{
+			"POSTGRES_PASSWORD=postgres",
+			"POSTGRES_USER=postgres",
+			"POSTGRES_DB=postgres",
+			"listen_addresses = '*'",
+		},
+	}, func(config *docker.HostConfig) {
+		// set AutoRemove to true so that stopped container goes away by itself
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("start postgres: %w", err)
+	}
+
+	purge := func() {
+		if err := pool.Purge(resource); err != nil {
+			log.Printf("pgtest: purge postgres: %s", err)
+		}
+	}
+
+	databaseURL := fmt.Sprintf("postgres://postgres:postgres@%s/postgres?sslmode=disable", hostPort(resource))
+	resource.Expire(dockerExpire) // Tell docker to hard kill the container
+	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
+	pool.MaxWait = dockerMaxWait
+
+	if err := pool.Retry(func() error {
+		ctx := context.Background()
+
+		conn, err := pgx.Connect(ctx, databaseURL)
+		if err != nil {
+			return fmt.Errorf("pgx connect: %w", err)
+		}
+		defer conn.Close(ctx)
+
+		if err := conn.Ping(ctx); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		purge()
+
+		return "", nil, fmt.Errorf("wait for postgres: %w", err)
+	}
+
+	return databaseURL, purge, nil
+}
+
+func hostPort(resource *dockertest.Resource) string {
+	dockerURL := os.Getenv("DOCKER_HOST")
+	if dockerURL == "" {
+		return resource.GetHostPort("5432/tcp")
+	}
+
+	u, err := url.Parse(dockerURL)
+	if err != nil {
+		return resource.GetHostPort("5432/tcp")
+	}
+
+	return net.JoinHostPort(u.Hostname(), resource.GetPort("5432/tcp"))
+}
+
+// setup creates the template database and migrates it.
+func (h *harness) setup(databaseURL string) error {
+	admin, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", URLEnv, err)
+	}
+
+	h.admin = admin
+	h.template = fmt.Sprintf("pgtest_template_%d", os.Getpid())
+
+	if err := h.exec("CREATE DATABASE " + pgx.Identifier{h.template}.Sanitize()); err != nil {
+		return fmt.Errorf("create template: %w", err)
+	}
+
+	if h.migrations == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.Connect(ctx, h.url(h.template))
+	if err != nil {
+		return fmt.Errorf("connect template: %w", err)
+	}
+	// the template cannot be copied while connected
+	defer pool.Close()
+
+	migrator, err := migrate.New(pool, h.migrations)
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("migrate template: %w", err)
+	}
+
+	return nil
+}
+
+// exec runs sql on the admin database.
+func (h *harness) exec(sql string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	conn, err := pgx.ConnectConfig(ctx, h.admin)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+
+	return nil
+}
+
+func (h *harness) url(database string) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(h.admin.User, h.admin.Password),
+		Host:   net.JoinHostPort(h.admin.Host, strconv.Itoa(int(h.admin.Port))),
+		Path:   database,
+	}
+
+	if h.admin.TLSConfig == nil {
+		u.RawQuery = "sslmode=disable"
+	}
+
+	return u.String()
+}
+
+// createDatabase copies the template into database, retrying while the template is still accessed,
+// e.g. by the connections of the migrations that are closing.
+func (h *harness) createDatabase(database string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sql := "CREATE DATABASE " + pgx.Identifier{database}.Sanitize() + " TEMPLATE " + pgx.Identifier{h.template}.Sanitize()
+
+	var err error
+
+	for attempt := 0; attempt < createAttempts; attempt++ {
+		var pgErr *pgconn.PgError
+		if err = h.exec(sql); err == nil || !errors.As(err, &pgErr) || pgErr.Code != sqlStateObjectInUse {
+			return err
+		}
+
+		time.Sleep(createBackoff)
+	}
+
+	return err
+}
+
+func (h *harness) dropDatabase(database string) {
+	if err := h.exec("DROP DATABASE IF EXISTS " + pgx.Identifier{database}.Sanitize() + " WITH (FORCE)"); err != nil {
+		log.Printf("pgtest: drop database %s: %s", database, err)
+	}
+}
+
+// newDatabase creates a database from the template and returns its name, the database is dropped when t ends.
+func newDatabase(t testing.TB) (*harness, string) {
+	t.Helper()
+
+	if current == nil {
+		t.Fatal("pgtest: Main must be called by TestMain")
+	}
+
+	h := current
+	database := fmt.Sprintf("pgtest_%d_%d", os.Getpid(), atomic.AddInt64(&h.next, 1))
+
+	if err := h.createDatabase(database); err != nil {
+		t.Fatalf("pgtest: create database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		h.dropDatabase(database)
+	})
+
+	return h, database
+}
+
+// Config creates a database from the template and returns its Config, the database is dropped when t ends.
+func Config(t testing.TB) *pginit.Config {
+	t.Helper()
+
+	h, database := newDatabase(t)
+
+	return &pginit.Config{
+		User:     h.admin.User,
+		Password: h.admin.Password,
+		Host:     h.admin.Host,
+		Port:     strconv.Itoa(int(h.admin.Port)),
+		Database: database,
+	}
+}
+
+// URL creates a database from the template and returns its URL, for the drivers not configured with pginit.
+// The database is dropped when t ends.
+func URL(t testing.TB) string {
+	t.Helper()
+
+	h, database := newDatabase(t)
+
+	return h.url(database)
+}
+
+// New returns a PGInit with opts for a new database, see Config.
+func New(t testing.TB, opts ...pginit.Option) *pginit.PGInit {
+	t.Helper()
+
+	pgi, err := pginit.New(Config(t), opts...)
+	if err != nil {
+		t.Fatalf("pgtest: pginit: %v", err)
+	}
+
+	return pgi
+}
+
+// Pool returns a pool with opts on a new database, see Config, the pool is closed when t ends.
+func Pool(t testing.TB, opts ...pginit.Option) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := New(t, opts...).ConnPool(context.Background())
+	if err != nil {
+		t.Fatalf("pgtest: connect: %v", err)
+	}
+
+	// registered after the drop of the database, so that it runs before it
+	t.Cleanup(pool.Close)
+
+	return pool
+}
@@ -0,0 +1,53 @@
+package pgtest_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m, pgtest.WithMigrations(fstest.MapFS{
+		"1_accounts.up.sql":   {Data: []byte("CREATE TABLE accounts (id serial PRIMARY KEY, balance numeric NOT NULL)")},
+		"1_accounts.down.sql": {Data: []byte("DROP TABLE accounts")},
+	}))
+}
+
+func TestPool_Isolated(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			pool := pgtest.Pool(t, pginit.WithDecimalType())
+
+			if _, err := pool.Exec(ctx, "INSERT INTO accounts (balance) VALUES (1.5)"); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			var count int
+			if err := pool.QueryRow(ctx, "SELECT count(*) FROM accounts").Scan(&count); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			if count != 1 {
+				t.Errorf("expected 1 account in the test database but got %d", count)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	first, second := pgtest.Config(t), pgtest.Config(t)
+
+	if first.Database == second.Database {
+		t.Errorf("expected distinct databases but got %s twice", first.Database)
+	}
+}
@@ -4,21 +4,28 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/jackc/pgtype"
 	gofrs "github.com/jackc/pgtype/ext/gofrs-uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/log/zerologadapter"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/monacohq/golang-common/database/pginit/ext/decimal/ericlagergren"
 	"github.com/rs/zerolog"
 )
 
 const (
-	defaultMaxConns     = 25
-	defaultMaxIdleConns = 25
-	defaultMaxLifeTime  = 5 * time.Minute
+	defaultMaxConns    = 25
+	defaultPort        = "5432"
+	defaultMaxLifeTime = 5 * time.Minute
+
+	defaultReplicaHealthCheckPeriod = 10 * time.Second
+
+	base10    = 10
+	bitSize16 = 16
+	bitSize32 = 32
 )
 
 // Option configures PGInit behaviour.
@@ -29,14 +36,49 @@ type PGInit struct {
 	pgxConf         *pgxpool.Config
 	logLvl          pgx.LogLevel
 	customDataTypes []pgtype.DataType
+	defaultPgTypes  []defaultPgType
+	maxIdleConns    int32
+	pgBouncerMode   bool
+
+	replicas                 []Replica
+	replicaStrategy          ReplicaStrategy
+	replicaHealthCheckPeriod time.Duration
+
+	logger           *zerolog.Logger
+	reqIDKey         string
+	tenantKeyFromCtx string
+	connectRetry     *connectRetry
+
+	slowQueryThreshold time.Duration
+	queryStats         *QueryStats
+
+	beforeConnect []func(context.Context, *pgx.ConnConfig) error
+	afterConnect  []func(context.Context, *pgx.Conn) error
+	beforeAcquire []func(context.Context, *pgx.Conn) bool
+	afterRelease  []func(*pgx.Conn) bool
+}
+
+// defaultPgType maps the Go type of value to the data type registered under name, see pgtype.ConnInfo.RegisterDefaultPgType.
+type defaultPgType struct {
+	value interface{}
+	name  string
+}
+
+type connectRetry struct {
+	maxWait time.Duration
+	backoff time.Duration
 }
 
 // New initializes a PGInit using the provided Config and options. If
 // opts is not provided it will initializes PGInit with default configuration.
 func New(conf *Config, opts ...Option) (*PGInit, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
 	databaseURL := fmt.Sprintf(
 		"postgres://%s:%s@%s/%s",
-		conf.User, conf.Password, net.JoinHostPort(conf.Host, conf.Port), conf.Database,
+		conf.User, conf.Password, net.JoinHostPort(conf.Host, portOrDefault(conf.Port)), conf.Database,
 	)
 
 	pgxConf, err := pgxpool.ParseConfig(databaseURL)
@@ -49,45 +91,192 @@ func New(conf *Config, opts ...Option) (*PGInit, error) {
 		pgxConf.MaxConns = conf.MaxConns
 	}
 
-	pgxConf.MinConns = defaultMaxIdleConns
-	if conf.MaxIdleConns != 0 && conf.MaxConns >= conf.MaxIdleConns {
-		pgxConf.MinConns = conf.MaxIdleConns
-	} else {
-		pgxConf.MinConns = pgxConf.MaxConns
-	}
+	pgxConf.MinConns = conf.MinConns
 
 	pgxConf.MaxConnLifetime = defaultMaxLifeTime
 	if conf.MaxLifeTime != 0 {
 		pgxConf.MaxConnLifetime = conf.MaxLifeTime
 	}
 
+	pgxConf.MaxConnLifetimeJitter = conf.MaxConnLifetimeJitter
+
+	if conf.MaxConnIdleTime != 0 {
+		pgxConf.MaxConnIdleTime = conf.MaxConnIdleTime
+	}
+
+	if conf.HealthCheckPeriod != 0 {
+		pgxConf.HealthCheckPeriod = conf.HealthCheckPeriod
+	}
+
+	pgxConf.LazyConnect = conf.LazyConnect
+
 	pgi := &PGInit{
-		pgxConf: pgxConf,
-		logLvl:  pgx.LogLevelWarn,
+		pgxConf:                  pgxConf,
+		logLvl:                   pgx.LogLevelWarn,
+		maxIdleConns:             conf.MaxIdleConns,
+		replicas:                 conf.Replicas,
+		replicaStrategy:          RoundRobin,
+		replicaHealthCheckPeriod: defaultReplicaHealthCheckPeriod,
 	}
 
 	for _, opt := range opts {
 		opt(pgi)
 	}
 
-	pgi.pgxConf.AfterConnect = func(ctx context.Context, c *pgx.Conn) error {
-		for _, dataType := range pgi.customDataTypes {
-			c.ConnInfo().RegisterDataType(dataType)
-		}
+	if pgi.pgBouncerMode && pgi.tenantKeyFromCtx != "" {
+		return nil, fmt.Errorf("%w: WithTenant needs a session and cannot be used with WithPgBouncerMode", ErrInvalidConfig)
+	}
+
+	pgi.installQueryLogger()
+
+	if len(pgi.beforeConnect) > 0 {
+		pgi.pgxConf.BeforeConnect = pgi.runBeforeConnect
+	}
+
+	pgi.pgxConf.AfterConnect = pgi.runAfterConnect
 
-		return nil
+	if len(pgi.beforeAcquire) > 0 {
+		pgi.pgxConf.BeforeAcquire = pgi.runBeforeAcquire
+	}
+
+	if len(pgi.afterRelease) > 0 {
+		pgi.pgxConf.AfterRelease = pgi.runAfterRelease
 	}
 
 	return pgi, nil
 }
 
+func (pgi *PGInit) runAfterConnect(ctx context.Context, conn *pgx.Conn) error {
+	for _, dataType := range pgi.customDataTypes {
+		conn.ConnInfo().RegisterDataType(dataType)
+	}
+
+	for _, defaultType := range pgi.defaultPgTypes {
+		conn.ConnInfo().RegisterDefaultPgType(defaultType.value, defaultType.name)
+	}
+
+	for _, hook := range pgi.afterConnect {
+		if err := hook(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pgi *PGInit) runBeforeConnect(ctx context.Context, connConfig *pgx.ConnConfig) error {
+	for _, hook := range pgi.beforeConnect {
+		if err := hook(ctx, connConfig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pgi *PGInit) runBeforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	for _, hook := range pgi.beforeAcquire {
+		if !hook(ctx, conn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (pgi *PGInit) runAfterRelease(conn *pgx.Conn) bool {
+	for _, hook := range pgi.afterRelease {
+		if !hook(conn) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ConnPool initiates connection to database and return a pgxpool.Pool.
+// If WithConnectRetry is set, it keeps trying until the database answers to a ping.
 func (pgi *PGInit) ConnPool(ctx context.Context) (*pgxpool.Pool, error) {
-	pool, err := pgxpool.ConnectConfig(ctx, pgi.pgxConf)
+	return pgi.connPool(ctx, pgi.pgxConf)
+}
+
+func (pgi *PGInit) connPool(ctx context.Context, conf *pgxpool.Config) (*pgxpool.Pool, error) {
+	if pgi.connectRetry == nil {
+		return pgi.connect(ctx, conf)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pgi.connectRetry.maxWait)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		pool, err := pgi.connectAndPing(ctx, conf)
+		if err == nil {
+			return pool, nil
+		}
+
+		delay := jitteredBackoff(pgi.connectRetry.backoff, attempt)
+
+		if pgi.logger != nil {
+			pgi.logger.Warn().
+				Err(err).
+				Int("attempt", attempt+1).
+				Dur("retry_in", delay).
+				Msg("pginit: database not reachable")
+		}
+
+		if errWait := sleepContext(ctx, delay); errWait != nil {
+			return nil, fmt.Errorf("connect config after %d attempts: %w", attempt+1, err)
+		}
+	}
+}
+
+func (pgi *PGInit) connectAndPing(ctx context.Context, conf *pgxpool.Config) (*pgxpool.Pool, error) {
+	pool, err := pgi.connect(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(WithoutTenant(ctx)); err != nil {
+		pool.Close()
+
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	return pool, nil
+}
+
+// connect opens a pool with conf, closing the connections released while
+// Config.MaxIdleConns connections are already idle, as a soft cap.
+func (pgi *PGInit) connect(ctx context.Context, conf *pgxpool.Config) (*pgxpool.Pool, error) {
+	var poolRef atomic.Value
+
+	if maxIdleConns := pgi.maxIdleConns; maxIdleConns != 0 && maxIdleConns < conf.MaxConns {
+		conf = conf.Copy()
+		afterRelease := conf.AfterRelease
+		conf.AfterRelease = func(conn *pgx.Conn) bool {
+			if afterRelease != nil && !afterRelease(conn) {
+				return false
+			}
+
+			pool, ok := poolRef.Load().(*pgxpool.Pool)
+			if !ok {
+				return true
+			}
+
+			// the released connection is not counted as idle yet. The count is not
+			// synchronized with the other releases, hence a soft cap, which is enough
+			// to trim the pool after a burst.
+			return pool.Stat().IdleConns() < maxIdleConns
+		}
+	}
+
+	pool, err := pgxpool.ConnectConfig(ctx, conf)
 	if err != nil {
 		return nil, fmt.Errorf("connect config: %w", err)
 	}
 
+	poolRef.Store(pool)
+
 	return pool, nil
 }
 
@@ -96,6 +285,8 @@ func (pgi *PGInit) ConnPool(ctx context.Context) (*pgxpool.Pool, error) {
 // log with the request id. Only will log if the log level is equal and above pgx.LogLevelWarn.
 func WithLogger(logger *zerolog.Logger, reqIDKeyFromCtx string) Option {
 	return func(pgi *PGInit) {
+		pgi.logger = logger
+		pgi.reqIDKey = reqIDKeyFromCtx
 		pgi.pgxConf.ConnConfig.LogLevel = pgi.logLvl
 		pgi.pgxConf.ConnConfig.Logger = zerologadapter.NewLogger(*logger, zerologadapter.WithContextFunc(
 			func(ctx context.Context, logWith zerolog.Context) zerolog.Context {
@@ -129,24 +320,83 @@ func WithLogLevel(zLvl zerolog.Level) Option {
 	}
 }
 
-// WithDecimalType set pgx decimal type to ericlagergren/decimal.
-func WithDecimalType() Option {
+// WithDecimalType set pgx decimal type to the library selected by kind, ericlagergren/decimal
+// by default, e.g. WithDecimalType(Shopspring()).
+// Only the first kind is used.
+// numeric[] is scanned into slices, e.g. []decimal.Big, or slices of pointers when elements may be NULL,
+// and numrange into Numrange.
+func WithDecimalType(kind ...DecimalKind) Option {
+	newValue := EricLagergren()
+	if len(kind) > 0 {
+		newValue = kind[0]
+	}
+
 	return func(p *PGInit) {
-		p.customDataTypes = append(p.customDataTypes, pgtype.DataType{
-			Value: &ericlagergren.Numeric{},
-			Name:  "numeric",
-			OID:   pgtype.NumericOID,
-		})
+		p.defaultPgTypes = append(p.defaultPgTypes, newValue.defaultPgTypes()...)
+		p.customDataTypes = append(p.customDataTypes,
+			pgtype.DataType{
+				Value: newValue(),
+				Name:  "numeric",
+				OID:   pgtype.NumericOID,
+			},
+			arrayDataType("_numeric", pgtype.NumericArrayOID, pgtype.NumericOID, newValue),
+			pgtype.DataType{
+				Value: newNumrangeType(nullable(newValue)),
+				Name:  "numrange",
+				OID:   pgtype.NumrangeOID,
+			},
+		)
 	}
 }
 
 // WithUUIDType set pgx uuid type to gofrs/uuid.
+// uuid[] is scanned into []uuid.UUID, or []*uuid.UUID when elements may be NULL.
 func WithUUIDType() Option {
+	newElement := func() pgtype.ValueTranscoder { return &gofrs.UUID{} }
+
 	return func(p *PGInit) {
-		p.customDataTypes = append(p.customDataTypes, pgtype.DataType{
-			Value: &gofrs.UUID{},
-			Name:  "uuid",
-			OID:   pgtype.UUIDOID,
-		})
+		p.defaultPgTypes = append(p.defaultPgTypes,
+			defaultPgType{value: uuid.UUID{}, name: "uuid"},
+			defaultPgType{value: []uuid.UUID{}, name: "_uuid"},
+			defaultPgType{value: []*uuid.UUID{}, name: "_uuid"},
+		)
+		p.customDataTypes = append(p.customDataTypes,
+			pgtype.DataType{
+				Value: &gofrs.UUID{},
+				Name:  "uuid",
+				OID:   pgtype.UUIDOID,
+			},
+			arrayDataType("_uuid", pgtype.UUIDArrayOID, pgtype.UUIDOID, newElement),
+		)
+	}
+}
+
+// WithReplicaStrategy set the strategy used by the Router to pick a replica for reads.
+func WithReplicaStrategy(strategy ReplicaStrategy) Option {
+	return func(pgi *PGInit) {
+		pgi.replicaStrategy = strategy
+	}
+}
+
+// WithReplicaHealthCheck set how often the Router pings its replicas to detect
+// the ones going down or coming back, default 10s. A replica not answering within
+// the period, or 2s if shorter, is marked unhealthy.
+func WithReplicaHealthCheck(period time.Duration) Option {
+	return func(pgi *PGInit) {
+		if period > 0 {
+			pgi.replicaHealthCheckPeriod = period
+		}
+	}
+}
+
+// WithConnectRetry makes ConnPool retry to connect and ping the database for up to maxWait,
+// waiting a jittered exponential backoff starting at backoff between attempts.
+// Each failed attempt is logged with the logger given to WithLogger.
+func WithConnectRetry(maxWait, backoff time.Duration) Option {
+	return func(pgi *PGInit) {
+		pgi.connectRetry = &connectRetry{
+			maxWait: maxWait,
+			backoff: backoff,
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package pginit_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
@@ -15,6 +16,7 @@ import (
 	"github.com/ericlagergren/decimal"
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/monacohq/golang-common/database/pginit"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
@@ -110,6 +112,57 @@ func getHostPort(resource *dockertest.Resource, id string) string {
 	return u.Hostname() + ":" + resource.GetPort(id)
 }
 
+// newTestPGInit returns a PGInit connecting to the test database with at most 2 connections.
+func newTestPGInit(t *testing.T, opts ...pginit.Option) *pginit.PGInit {
+	t.Helper()
+
+	return newTestPGInitWithMaxConns(t, 2, opts...)
+}
+
+// newTestPGInitWithMaxConns returns a PGInit connecting to the test database with at most maxConns connections.
+func newTestPGInitWithMaxConns(t *testing.T, maxConns int32, opts ...pginit.Option) *pginit.PGInit {
+	t.Helper()
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: maxConns,
+		},
+		opts...,
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	return pgi
+}
+
+// newTestPool returns a pool of at most 2 connections to the test database, closed when the test ends.
+func newTestPool(t *testing.T, opts ...pginit.Option) *pgxpool.Pool {
+	t.Helper()
+
+	return newTestPoolWithMaxConns(t, 2, opts...)
+}
+
+// newTestPoolWithMaxConns returns a pool of at most maxConns connections to the test database,
+// closed when the test ends.
+func newTestPoolWithMaxConns(t *testing.T, maxConns int32, opts ...pginit.Option) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := newTestPGInitWithMaxConns(t, maxConns, opts...).ConnPool(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
 func TestConnPool(t *testing.T) {
 	t.Parallel()
 
@@ -142,9 +195,11 @@ func TestConnPool(t *testing.T) {
 			want: want{
 				Err: nil,
 				Config: pginit.Config{
-					MaxConns:     25,
-					MaxIdleConns: 25,
-					MaxLifeTime:  5 * time.Minute,
+					MaxConns:          25,
+					MinConns:          0,
+					MaxLifeTime:       5 * time.Minute,
+					MaxConnIdleTime:   30 * time.Minute,
+					HealthCheckPeriod: time.Minute,
 				},
 			},
 		},
@@ -152,22 +207,29 @@ func TestConnPool(t *testing.T) {
 			name: "expecting no error with custom connection setting",
 			args: args{
 				pginit.Config{
-					Host:         testHost,
-					Port:         testPort,
-					User:         "postgres",
-					Password:     "postgres",
-					Database:     "datawarehouse",
-					MaxConns:     15,
-					MaxIdleConns: 10,
-					MaxLifeTime:  10 * time.Minute,
+					Host:                  testHost,
+					Port:                  testPort,
+					User:                  "postgres",
+					Password:              "postgres",
+					Database:              "datawarehouse",
+					MaxConns:              15,
+					MinConns:              2,
+					MaxIdleConns:          10,
+					MaxLifeTime:           10 * time.Minute,
+					MaxConnLifetimeJitter: time.Minute,
+					MaxConnIdleTime:       2 * time.Minute,
+					HealthCheckPeriod:     30 * time.Second,
 				},
 			},
 			want: want{
 				Err: nil,
 				Config: pginit.Config{
-					MaxConns:     15,
-					MaxIdleConns: 10,
-					MaxLifeTime:  10 * time.Minute,
+					MaxConns:              15,
+					MinConns:              2,
+					MaxLifeTime:           10 * time.Minute,
+					MaxConnLifetimeJitter: time.Minute,
+					MaxConnIdleTime:       2 * time.Minute,
+					HealthCheckPeriod:     30 * time.Second,
 				},
 			},
 		},
@@ -199,8 +261,17 @@ func TestConnPool(t *testing.T) {
 			if db.Config().MaxConnLifetime != tt.want.Config.MaxLifeTime {
 				t.Errorf("expected (%v) but got (%v)", tt.want.Config.MaxLifeTime, db.Config().MaxConnLifetime)
 			}
-			if db.Config().MinConns != tt.want.Config.MaxIdleConns {
-				t.Errorf("expected (%v) but got (%v)", tt.want.Config.MaxIdleConns, db.Config().MinConns)
+			if db.Config().MinConns != tt.want.Config.MinConns {
+				t.Errorf("expected (%v) but got (%v)", tt.want.Config.MinConns, db.Config().MinConns)
+			}
+			if db.Config().MaxConnLifetimeJitter != tt.want.Config.MaxConnLifetimeJitter {
+				t.Errorf("expected (%v) but got (%v)", tt.want.Config.MaxConnLifetimeJitter, db.Config().MaxConnLifetimeJitter)
+			}
+			if db.Config().MaxConnIdleTime != tt.want.Config.MaxConnIdleTime {
+				t.Errorf("expected (%v) but got (%v)", tt.want.Config.MaxConnIdleTime, db.Config().MaxConnIdleTime)
+			}
+			if db.Config().HealthCheckPeriod != tt.want.Config.HealthCheckPeriod {
+				t.Errorf("expected (%v) but got (%v)", tt.want.Config.HealthCheckPeriod, db.Config().HealthCheckPeriod)
 			}
 		})
 	}
@@ -509,6 +580,143 @@ func TestConnPoolWithCustomTypes_CRUD(t *testing.T) {
 	}
 }
 
+func TestConnPoolWithConnectRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		port         string
+		maxWait      time.Duration
+		cancelled    bool
+		expectErr    bool
+		expectLogged bool
+	}{
+		{
+			name:         "reachable database connects at first attempt",
+			port:         testPort,
+			maxWait:      5 * time.Second,
+			expectErr:    false,
+			expectLogged: false,
+		},
+		{
+			name:         "unreachable database gives up after max wait",
+			port:         "1",
+			maxWait:      time.Second,
+			expectErr:    true,
+			expectLogged: true,
+		},
+		{
+			name:         "cancelled context stops retrying",
+			port:         "1",
+			maxWait:      time.Minute,
+			cancelled:    true,
+			expectErr:    true,
+			expectLogged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			logger := zerolog.New(buf)
+
+			pgi, err := pginit.New(
+				&pginit.Config{
+					Host:     testHost,
+					Port:     tt.port,
+					User:     "postgres",
+					Password: "postgres",
+					Database: "datawarehouse",
+					MaxConns: 2,
+				},
+				pginit.WithLogger(&logger, "request-id"),
+				pginit.WithConnectRetry(tt.maxWait, 50*time.Millisecond),
+			)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.cancelled {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+
+			pool, err := pgi.ConnPool(ctx)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error to be %t but got: %v", tt.expectErr, err)
+			}
+
+			if err == nil {
+				defer pool.Close()
+			}
+
+			if elapsed := time.Since(start); elapsed > tt.maxWait+time.Second {
+				t.Errorf("expected to stop retrying within %v but took %v", tt.maxWait, elapsed)
+			}
+
+			if logged := strings.Contains(buf.String(), `"attempt":1`); logged != tt.expectLogged {
+				t.Errorf("expected attempt logged to be %t but got: %s", tt.expectLogged, buf.String())
+			}
+		})
+	}
+}
+
+func TestConnPoolMaxIdleConns(t *testing.T) {
+	t.Parallel()
+
+	pgi, err := pginit.New(&pginit.Config{
+		Host:         testHost,
+		Port:         testPort,
+		User:         "postgres",
+		Password:     "postgres",
+		Database:     "datawarehouse",
+		MaxConns:     4,
+		MaxIdleConns: 1,
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	conns := make([]*pgxpool.Conn, 0, 4)
+	for i := 0; i < 4; i++ {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		conns = append(conns, conn)
+	}
+
+	for _, conn := range conns {
+		conn.Release()
+		// AfterRelease runs asynchronously
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if idle := pool.Stat().IdleConns(); idle != 1 {
+		t.Errorf("expected 1 idle connection but got %d", idle)
+	}
+
+	if total := pool.Stat().TotalConns(); total != 1 {
+		t.Errorf("expected 1 open connection but got %d", total)
+	}
+}
+
 func BenchmarkConnPool(b *testing.B) {
 	for i := 0; i <= b.N; i++ {
 		ctx := context.Background()
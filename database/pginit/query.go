@@ -0,0 +1,339 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	// ErrMissingNamedArg is returned when a named parameter of a query has no value in NamedArgs.
+	ErrMissingNamedArg = errors.New("missing named argument")
+	// ErrUnknownColumn is returned when a column of a query has no field tagged with its name.
+	ErrUnknownColumn = errors.New("unknown column")
+
+	errMixedArgs = errors.New("NamedArgs must be the only argument")
+)
+
+// Querier runs queries, it is implemented by *pgxpool.Pool, *pgx.Conn, pgx.Tx and *Router.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// NamedArgs are the values of the named parameters of a query, e.g. NamedArgs{"user_id": 1} for :user_id.
+type NamedArgs map[string]interface{}
+
+// BindNamed rewrites the named parameters of sql, e.g. :user_id, into positional parameters $1, $2...
+// and returns the matching arguments, a parameter used several times is bound once.
+// Casts (::numeric), string literals, dollar-quoted bodies, quoted identifiers, comments
+// and array slices (arr[lo:hi]) are left untouched.
+// nolint: cyclop, gocognit // each kind of token is skipped separately
+func BindNamed(sql string, args NamedArgs) (string, []interface{}, error) {
+	var (
+		buf       strings.Builder
+		bound     []interface{}
+		positions = map[string]int{}
+		brackets  int
+	)
+
+	buf.Grow(len(sql))
+
+	for i := 0; i < len(sql); {
+		switch {
+		case sql[i] == '\'' || sql[i] == '"':
+			end := skipQuoted(sql, i, sql[i])
+			buf.WriteString(sql[i:end])
+			i = end
+		case (sql[i] == 'E' || sql[i] == 'e') && i+1 < len(sql) && sql[i+1] == '\'' && (i == 0 || !isIdentPart(sql[i-1])):
+			end := skipString(sql, i+2, true) + 1
+			if end > len(sql) {
+				end = len(sql)
+			}
+
+			buf.WriteString(sql[i:end])
+			i = end
+		case sql[i] == '$' && (i == 0 || !isIdentPart(sql[i-1])) && dollarTag(sql, i) != "":
+			tag := dollarTag(sql, i)
+
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end = i + 2*len(tag) + end
+			}
+
+			buf.WriteString(sql[i:end])
+			i = end
+		case sql[i] == '[' || sql[i] == ']':
+			if sql[i] == '[' {
+				brackets++
+			} else if brackets > 0 {
+				brackets--
+			}
+
+			buf.WriteByte(sql[i])
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+
+			buf.WriteString(sql[i : i+end])
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 4
+			}
+
+			buf.WriteString(sql[i : i+end])
+			i += end
+		case strings.HasPrefix(sql[i:], "::"):
+			buf.WriteString("::")
+			i += 2
+		case sql[i] == ':' && brackets > 0 && isSliceBound(sql[:i]):
+			buf.WriteByte(':')
+			i++
+		case sql[i] == ':' && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			end := i + 2
+			for end < len(sql) && isIdentPart(sql[end]) {
+				end++
+			}
+
+			name := sql[i+1 : end]
+
+			position, ok := positions[name]
+			if !ok {
+				value, ok := args[name]
+				if !ok {
+					return "", nil, fmt.Errorf("%w: %s", ErrMissingNamedArg, name)
+				}
+
+				bound = append(bound, value)
+				position = len(bound)
+				positions[name] = position
+			}
+
+			buf.WriteByte('$')
+			buf.WriteString(strconv.Itoa(position))
+			i = end
+		default:
+			buf.WriteByte(sql[i])
+			i++
+		}
+	}
+
+	return buf.String(), bound, nil
+}
+
+// skipQuoted returns the index after the quoted token starting at start, doubled quotes are escapes.
+func skipQuoted(sql string, start int, quote byte) int {
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+
+			continue
+		}
+
+		return i + 1
+	}
+
+	return len(sql)
+}
+
+// isSliceBound reports whether a colon inside brackets, preceded by before, separates the bounds
+// of an array slice, i.e. it follows [, an identifier, a number or a closing parenthesis or bracket.
+func isSliceBound(before string) bool {
+	before = strings.TrimRight(before, " \t\r\n")
+	if before == "" {
+		return false
+	}
+
+	last := before[len(before)-1]
+
+	return last == '[' || last == ')' || last == ']' || isIdentPart(last)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || ('0' <= c && c <= '9')
+}
+
+// bindArgs binds NamedArgs when it is the only argument and returns positional arguments as is.
+func bindArgs(sql string, args []interface{}) (string, []interface{}, error) {
+	for i, arg := range args {
+		named, ok := arg.(NamedArgs)
+		if !ok {
+			continue
+		}
+
+		if len(args) != 1 || i != 0 {
+			return "", nil, errMixedArgs
+		}
+
+		return BindNamed(sql, named)
+	}
+
+	return sql, args, nil
+}
+
+// Select runs sql on db and scans every row into a T.
+// When T is a struct, or a pointer to a struct, with fields tagged db:"column",
+// the columns are scanned into the fields of the same name and a column without field is an error;
+// otherwise the single column of the rows is scanned into T.
+// Fields are scanned by the data types registered on the connection,
+// so decimal.Big and uuid.UUID fields need WithDecimalType and WithUUIDType.
+// args are positional, $1, $2..., or a single NamedArgs for named parameters, see BindNamed.
+func Select[T any](ctx context.Context, db Querier, sql string, args ...interface{}) ([]T, error) {
+	var result []T
+
+	err := query(ctx, db, sql, args, func(row T) bool {
+		result = append(result, row)
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select: %w", err)
+	}
+
+	return result, nil
+}
+
+// Get runs sql on db like Select and returns the first row, or an error wrapping pgx.ErrNoRows.
+func Get[T any](ctx context.Context, db Querier, sql string, args ...interface{}) (T, error) {
+	var (
+		result T
+		found  bool
+	)
+
+	err := query(ctx, db, sql, args, func(row T) bool {
+		result, found = row, true
+
+		return false
+	})
+	if err == nil && !found {
+		err = pgx.ErrNoRows
+	}
+
+	if err != nil {
+		var zero T
+
+		return zero, fmt.Errorf("get: %w", err)
+	}
+
+	return result, nil
+}
+
+// query runs sql and calls yield with each row until it returns false.
+func query[T any](ctx context.Context, db Querier, sql string, args []interface{}, yield func(T) bool) error {
+	sql, args, err := bindArgs(sql, args)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	scan, err := newRowScanner[T](rows)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return err
+		}
+
+		if !yield(row) {
+			break
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows: %w", err)
+	}
+
+	return nil
+}
+
+// newRowScanner returns a func scanning a row of rows into a T, by column name when T is a struct with db tags.
+func newRowScanner[T any](rows pgx.Rows) (func(pgx.Rows) (T, error), error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	var fields []structField
+
+	if typ.Kind() == reflect.Struct || (typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct) {
+		// cannot fail for structs
+		fields, _ = structFields(typ)
+	}
+
+	if len(fields) == 0 {
+		return func(rows pgx.Rows) (T, error) {
+			var row T
+			if err := rows.Scan(&row); err != nil {
+				return row, fmt.Errorf("scan: %w", err)
+			}
+
+			return row, nil
+		}, nil
+	}
+
+	byColumn := make(map[string][]int, len(fields))
+	for _, field := range fields {
+		byColumn[field.column] = field.index
+	}
+
+	descriptions := rows.FieldDescriptions()
+	indexes := make([][]int, len(descriptions))
+
+	for i, description := range descriptions {
+		index, ok := byColumn[string(description.Name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, description.Name)
+		}
+
+		indexes[i] = index
+	}
+
+	return func(rows pgx.Rows) (T, error) {
+		var row T
+
+		value := reflect.ValueOf(&row).Elem()
+		if value.Kind() == reflect.Ptr {
+			value.Set(reflect.New(typ.Elem()))
+			value = value.Elem()
+		}
+
+		dest := make([]interface{}, len(indexes))
+		for i, index := range indexes {
+			dest[i] = value.FieldByIndex(index).Addr().Interface()
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return row, fmt.Errorf("scan: %w", err)
+		}
+
+		return row, nil
+	}, nil
+}
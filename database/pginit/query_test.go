@@ -0,0 +1,150 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestBindNamed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		sql          string
+		args         pginit.NamedArgs
+		expectedSQL  string
+		expectedArgs []interface{}
+		expectErr    error
+	}{
+		{
+			name:         "parameters are numbered by first use",
+			sql:          "SELECT * FROM t WHERE a = :a AND b = :b OR a > :a",
+			args:         pginit.NamedArgs{"a": 1, "b": "x"},
+			expectedSQL:  "SELECT * FROM t WHERE a = $1 AND b = $2 OR a > $1",
+			expectedArgs: []interface{}{1, "x"},
+		},
+		{
+			name:         "casts are kept",
+			sql:          "SELECT :price::numeric(10, 2)",
+			args:         pginit.NamedArgs{"price": "1.5"},
+			expectedSQL:  "SELECT $1::numeric(10, 2)",
+			expectedArgs: []interface{}{"1.5"},
+		},
+		{
+			name:         "literals, identifiers and comments are kept",
+			sql:          "SELECT ':a', \"b:c\", 'it''s :d' -- :e\n, :user_id /* :f */",
+			args:         pginit.NamedArgs{"user_id": 7},
+			expectedSQL:  "SELECT ':a', \"b:c\", 'it''s :d' -- :e\n, $1 /* :f */",
+			expectedArgs: []interface{}{7},
+		},
+		{
+			name:         "dollar-quoted bodies are kept",
+			sql:          "DO $$ BEGIN PERFORM :a; END $$; SELECT $fn$ :b $$ $fn$, :user_id",
+			args:         pginit.NamedArgs{"user_id": 7},
+			expectedSQL:  "DO $$ BEGIN PERFORM :a; END $$; SELECT $fn$ :b $$ $fn$, $1",
+			expectedArgs: []interface{}{7},
+		},
+		{
+			name:         "escape strings are kept",
+			sql:          `SELECT E'it\'s :a', :user_id`,
+			args:         pginit.NamedArgs{"user_id": 7},
+			expectedSQL:  `SELECT E'it\'s :a', $1`,
+			expectedArgs: []interface{}{7},
+		},
+		{
+			name:         "array slices are kept",
+			sql:          "SELECT arr[lo:hi], arr[:hi], arr[1:n], arr[(lo) : hi][:idx + 1:m], arr[1 + :offset]",
+			args:         pginit.NamedArgs{"offset": 2},
+			expectedSQL:  "SELECT arr[lo:hi], arr[:hi], arr[1:n], arr[(lo) : hi][:idx + 1:m], arr[1 + $1]",
+			expectedArgs: []interface{}{2},
+		},
+		{
+			name:      "missing argument",
+			sql:       "SELECT :a, :b",
+			args:      pginit.NamedArgs{"a": 1},
+			expectErr: pginit.ErrMissingNamedArg,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sql, args, err := pginit.BindNamed(tt.sql, tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected error %v but got: %v", tt.expectErr, err)
+			}
+
+			if sql != tt.expectedSQL {
+				t.Errorf("expected %q but got %q", tt.expectedSQL, sql)
+			}
+
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("expected args %v but got %v", tt.expectedArgs, args)
+			}
+		})
+	}
+}
+
+type queryAccount struct {
+	ID    uuid.UUID   `db:"id"`
+	Price decimal.Big `db:"price"`
+	Name  *string     `db:"name"`
+}
+
+func TestSelectGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pool := newTestPool(t, pginit.WithDecimalType(), pginit.WithUUIDType())
+
+	id := uuid.Must(uuid.NewV4())
+
+	accounts, err := pginit.Select[queryAccount](ctx, pool,
+		"SELECT :id::uuid AS id, price, NULL::text AS name FROM unnest(ARRAY[1.5, 2.25]::numeric[]) AS price",
+		pginit.NamedArgs{"id": id},
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if len(accounts) != 2 || accounts[0].ID != id || accounts[1].Price.Cmp(decimal.New(225, 2)) != 0 ||
+		accounts[0].Name != nil {
+		t.Errorf("unexpected accounts %+v", accounts)
+	}
+
+	account, err := pginit.Get[*queryAccount](ctx, pool, "SELECT $1::uuid AS id, 3.5 AS price, 'a' AS name", id)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if account.ID != id || account.Price.Cmp(decimal.New(35, 1)) != 0 || account.Name == nil || *account.Name != "a" {
+		t.Errorf("unexpected account %+v", account)
+	}
+
+	count, err := pginit.Get[int](ctx, pool, "SELECT count(*) FROM generate_series(1, :n)", pginit.NamedArgs{"n": 3})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if count != 3 {
+		t.Errorf("expected 3 but got %d", count)
+	}
+
+	if _, err := pginit.Get[queryAccount](ctx, pool, "SELECT $1::uuid AS id WHERE false", id); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("expected pgx.ErrNoRows but got: %v", err)
+	}
+
+	if _, err := pginit.Get[queryAccount](ctx, pool, "SELECT 1 AS other"); !errors.Is(err, pginit.ErrUnknownColumn) {
+		t.Errorf("expected ErrUnknownColumn but got: %v", err)
+	}
+}
@@ -0,0 +1,423 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// queryStatsSamples is the number of latest durations kept per fingerprint to compute percentiles.
+	queryStatsSamples = 1024
+	// maxLoggedSQLLen truncates the statements written by the slow query log, in bytes.
+	maxLoggedSQLLen = 2048
+
+	percentile50 = 50
+	percentile99 = 99
+	percent      = 100
+)
+
+// placeholderList matches lists of placeholders such as ($1, $2, $3) so that
+// statements built with a variable number of arguments share a fingerprint.
+var placeholderList = regexp.MustCompile(`\((\s*(\?|\$\d+)\s*,)+\s*(\?|\$\d+)\s*\)`) // nolint: gochecknoglobals, nolintlint
+
+// QueryStats aggregates the executed statements per fingerprint, the SQL with
+// literals replaced by ? and whitespaces collapsed.
+type QueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*queryStat
+}
+
+type queryStat struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+}
+
+// QueryStat is a snapshot of the statistics of a statement fingerprint.
+type QueryStat struct {
+	Fingerprint string
+	Count       int64
+	Errors      int64
+	Total       time.Duration
+	P50         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+}
+
+// NewQueryStats returns an empty QueryStats, to give to WithQueryStats.
+func NewQueryStats() *QueryStats {
+	return &QueryStats{stats: map[string]*queryStat{}}
+}
+
+func (s *QueryStats) record(fingerprint string, duration time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.stats[fingerprint]
+	if !ok {
+		stat = &queryStat{}
+		s.stats[fingerprint] = stat
+	}
+
+	stat.count++
+	stat.total += duration
+
+	if failed {
+		stat.errors++
+	}
+
+	if duration > stat.max {
+		stat.max = duration
+	}
+
+	if len(stat.samples) < queryStatsSamples {
+		stat.samples = append(stat.samples, duration)
+
+		return
+	}
+
+	stat.samples[stat.next] = duration
+	stat.next = (stat.next + 1) % queryStatsSamples
+}
+
+// Snapshot returns the statistics of every fingerprint, the most time consuming first.
+// Percentiles are computed over the latest executions of each fingerprint.
+func (s *QueryStats) Snapshot() []QueryStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make([]QueryStat, 0, len(s.stats))
+
+	for fingerprint, stat := range s.stats {
+		samples := make([]time.Duration, len(stat.samples))
+		copy(samples, stat.samples)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+		snapshot = append(snapshot, QueryStat{
+			Fingerprint: fingerprint,
+			Count:       stat.count,
+			Errors:      stat.errors,
+			Total:       stat.total,
+			P50:         samples[(len(samples)-1)*percentile50/percent],
+			P99:         samples[(len(samples)-1)*percentile99/percent],
+			Max:         stat.max,
+		})
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Total == snapshot[j].Total {
+			return snapshot[i].Fingerprint < snapshot[j].Fingerprint
+		}
+
+		return snapshot[i].Total > snapshot[j].Total
+	})
+
+	return snapshot
+}
+
+// Reset drops all the statistics.
+func (s *QueryStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = map[string]*queryStat{}
+}
+
+// Dump writes the Snapshot as a table to w, e.g. from a debug endpoint or on SIGUSR1.
+func (s *QueryStats) Dump(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) // nolint: gomnd
+
+	fmt.Fprintln(tw, "COUNT\tERRORS\tTOTAL\tP50\tP99\tMAX\tQUERY")
+
+	for _, stat := range s.Snapshot() {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			stat.Count, stat.Errors, stat.Total, stat.P50, stat.P99, stat.Max, stat.Fingerprint)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("dump query stats: %w", err)
+	}
+
+	return nil
+}
+
+// WithSlowQueryLog logs with the logger given to WithLogger the statements taking longer
+// than threshold, with their sanitized SQL, number of arguments, rows and duration, and
+// the request id found in the context under the key given to WithLogger.
+// Argument values are never logged.
+//
+// The statements are observed through the pgx logger, so the pgx log level is raised to
+// pgx.LogLevelInfo: pgx then builds a log entry with the encoded arguments for every
+// statement, which costs a few allocations per statement even when it is not slow.
+func WithSlowQueryLog(threshold time.Duration) Option {
+	return func(pgi *PGInit) {
+		pgi.slowQueryThreshold = threshold
+	}
+}
+
+// WithQueryStats records the count, errors and latencies of every statement into stats.
+// Like WithSlowQueryLog, it raises the pgx log level to pgx.LogLevelInfo, with the same cost.
+func WithQueryStats(stats *QueryStats) Option {
+	return func(pgi *PGInit) {
+		pgi.queryStats = stats
+	}
+}
+
+// queryLogger is a pgx.Logger receiving the statements executions, it forwards
+// the other log lines to the logger set by WithLogger at its own level.
+type queryLogger struct {
+	next      pgx.Logger
+	nextLevel pgx.LogLevel
+
+	logger    *zerolog.Logger
+	reqIDKey  string
+	threshold time.Duration
+	stats     *QueryStats
+}
+
+// installQueryLogger wraps the pgx logger when WithSlowQueryLog or WithQueryStats is used,
+// statements are only logged by pgx from pgx.LogLevelInfo. pgx v4 has no other hook on the
+// statements of a *pgxpool.Pool, the lines below the level set by WithLogLevel are dropped
+// by the queryLogger instead of being written.
+func (pgi *PGInit) installQueryLogger() {
+	if (pgi.slowQueryThreshold <= 0 || pgi.logger == nil) && pgi.queryStats == nil {
+		return
+	}
+
+	connConfig := pgi.pgxConf.ConnConfig
+
+	ql := &queryLogger{
+		next:      connConfig.Logger,
+		nextLevel: connConfig.LogLevel,
+		logger:    pgi.logger,
+		reqIDKey:  pgi.reqIDKey,
+		threshold: pgi.slowQueryThreshold,
+		stats:     pgi.queryStats,
+	}
+
+	connConfig.Logger = ql
+	if connConfig.LogLevel < pgx.LogLevelInfo {
+		connConfig.LogLevel = pgx.LogLevelInfo
+	}
+}
+
+func (ql *queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch msg {
+	case "Exec", "Query", "CopyFrom":
+		ql.observe(ctx, msg, data)
+	}
+
+	if ql.next != nil && level <= ql.nextLevel {
+		ql.next.Log(ctx, level, msg, data)
+	}
+}
+
+func (ql *queryLogger) observe(ctx context.Context, msg string, data map[string]interface{}) {
+	duration, _ := data["time"].(time.Duration)
+	err, _ := data["err"].(error)
+
+	var (
+		sql  string
+		rows int64
+	)
+
+	switch msg {
+	case "CopyFrom":
+		tableName, _ := data["tableName"].(pgx.Identifier)
+		columnNames, _ := data["columnNames"].([]string)
+		sql = fmt.Sprintf("COPY %s (%s) FROM STDIN", tableName.Sanitize(), strings.Join(columnNames, ", "))
+		rows, _ = data["rowCount"].(int64)
+	case "Exec":
+		sql, _ = data["sql"].(string)
+		if commandTag, ok := data["commandTag"].(pgconn.CommandTag); ok {
+			rows = commandTag.RowsAffected()
+		}
+	default:
+		sql, _ = data["sql"].(string)
+		if rowCount, ok := data["rowCount"].(int); ok {
+			rows = int64(rowCount)
+		}
+	}
+
+	fingerprint := fingerprintSQL(sql)
+
+	if ql.stats != nil {
+		ql.stats.record(fingerprint, duration, err != nil)
+	}
+
+	if ql.logger == nil || ql.threshold <= 0 || duration < ql.threshold {
+		return
+	}
+
+	args, _ := data["args"].([]interface{})
+
+	event := ql.logger.Warn().
+		Err(err).
+		Str("sql", truncateSQL(fingerprint, maxLoggedSQLLen)).
+		Int("args", len(args)).
+		Int64("rows", rows).
+		Dur("duration", duration)
+
+	if ql.reqIDKey != "" {
+		if reqID, ok := ctx.Value(ql.reqIDKey).(string); ok {
+			event = event.Str(ql.reqIDKey, reqID)
+		}
+	}
+
+	event.Msg("pginit: slow query")
+}
+
+// fingerprintSQL replaces the literals of sql by ?, E'...' and $$...$$ included, and collapses whitespaces
+// and comments, so that statements only differing by their values are aggregated and no value is logged.
+// nolint: cyclop // single pass lexer
+func fingerprintSQL(sql string) string {
+	var builder strings.Builder
+
+	builder.Grow(len(sql))
+
+	space := false
+
+	for i := 0; i < len(sql); i++ {
+		char := sql[i]
+
+		switch {
+		case char == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+
+			space = true
+		case char == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+
+			space = true
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			space = true
+		case char == '\'':
+			i = skipString(sql, i+1, false)
+
+			writeToken(&builder, &space, "?")
+		case (char == 'E' || char == 'e') && i+1 < len(sql) && sql[i+1] == '\'' && !continuesIdentifier(sql, i):
+			// E'...' accepts backslash escapes, e.g. E'it\'s'
+			i = skipString(sql, i+2, true)
+
+			writeToken(&builder, &space, "?")
+		case char == '$' && !continuesIdentifier(sql, i) && dollarTag(sql, i) != "":
+			// $$...$$ and $tag$...$tag$ are dollar-quoted literals, $1 is a placeholder
+			tag := dollarTag(sql, i)
+
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag) - 1
+			}
+
+			writeToken(&builder, &space, "?")
+		case isDigit(char) && !continuesIdentifier(sql, i):
+			for i+1 < len(sql) && (isDigit(sql[i+1]) || sql[i+1] == '.') {
+				i++
+			}
+
+			writeToken(&builder, &space, "?")
+		default:
+			writeToken(&builder, &space, sql[i:i+1])
+		}
+	}
+
+	return placeholderList.ReplaceAllString(builder.String(), "(...)")
+}
+
+// skipString returns the index of the quote closing the string literal starting at start,
+// a doubled quote escapes a quote and, with backslashes, a backslash escapes the next character.
+func skipString(sql string, start int, backslashes bool) int {
+	for i := start; i < len(sql); i++ {
+		switch {
+		case backslashes && sql[i] == '\\':
+			i++
+		case sql[i] == '\'' && i+1 < len(sql) && sql[i+1] == '\'':
+			i++
+		case sql[i] == '\'':
+			return i
+		}
+	}
+
+	return len(sql)
+}
+
+// dollarTag returns the tag, e.g. $$ or $body$, of the dollar-quoted literal starting at i, if any.
+func dollarTag(sql string, i int) string {
+	for j := i + 1; j < len(sql); j++ {
+		char := sql[j]
+		if char == '$' {
+			return sql[i : j+1]
+		}
+
+		letter := char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char >= utf8.RuneSelf
+		if !letter && (!isDigit(char) || j == i+1) {
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// truncateSQL cuts sql to at most max bytes without splitting a multi-byte character.
+func truncateSQL(sql string, max int) string {
+	if len(sql) <= max {
+		return sql
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(sql[cut]) {
+		cut--
+	}
+
+	return sql[:cut] + "..."
+}
+
+func writeToken(builder *strings.Builder, space *bool, token string) {
+	if *space && builder.Len() > 0 {
+		builder.WriteByte(' ')
+	}
+
+	*space = false
+
+	builder.WriteString(token)
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// continuesIdentifier reports if the digit at i is part of an identifier or a $n placeholder.
+func continuesIdentifier(sql string, i int) bool {
+	if i == 0 {
+		return false
+	}
+
+	prev := sql[i-1]
+
+	return prev == '$' || prev == '_' || prev == '"' || isDigit(prev) ||
+		(prev >= 'a' && prev <= 'z') || (prev >= 'A' && prev <= 'Z')
+}
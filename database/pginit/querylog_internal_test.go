@@ -0,0 +1,121 @@
+package pginit
+
+import "testing"
+
+func TestFingerprintSQL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{
+			name:     "literals and whitespaces",
+			sql:      "SELECT *\n\tFROM t WHERE a = 'x' AND b = 1.5",
+			expected: "SELECT * FROM t WHERE a = ? AND b = ?",
+		},
+		{
+			name:     "doubled quote inside a string",
+			sql:      "SELECT 'it''s', 1",
+			expected: "SELECT ?, ?",
+		},
+		{
+			name:     "backslash escaped quote inside an E string",
+			sql:      `SELECT E'it\'s secret', e'\\', 1`,
+			expected: "SELECT ?, ?, ?",
+		},
+		{
+			name:     "identifier ending with e is not an E string",
+			sql:      "SELECT name'x'",
+			expected: "SELECT name?",
+		},
+		{
+			name:     "dollar-quoted literal",
+			sql:      "SELECT $$it's 'secret'$$, 2",
+			expected: "SELECT ?, ?",
+		},
+		{
+			name:     "tagged dollar-quoted literal containing $$",
+			sql:      "SELECT $body$ a $$ 'secret' $body$ FROM t",
+			expected: "SELECT ? FROM t",
+		},
+		{
+			name:     "placeholders are kept",
+			sql:      "SELECT $1::int + $2, a$b FROM t",
+			expected: "SELECT $1::int + $2, a$b FROM t",
+		},
+		{
+			name:     "unterminated dollar-quoted literal",
+			sql:      "SELECT $x$ secret",
+			expected: "SELECT ?",
+		},
+		{
+			name:     "comments",
+			sql:      "SELECT 1 -- secret\n/* secret */ FROM t",
+			expected: "SELECT ? FROM t",
+		},
+		{
+			name:     "multi-byte characters",
+			sql:      "SELECT 'é' AS café",
+			expected: "SELECT ? AS café",
+		},
+		{
+			name:     "IN list",
+			sql:      "SELECT * FROM t WHERE id IN (1, 2, 3)",
+			expected: "SELECT * FROM t WHERE id IN (...)",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := fingerprintSQL(tt.sql); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTruncateSQL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		sql      string
+		max      int
+		expected string
+	}{
+		{
+			name:     "short enough",
+			sql:      "SELECT ?",
+			max:      8,
+			expected: "SELECT ?",
+		},
+		{
+			name:     "cut on a byte",
+			sql:      "SELECT ?",
+			max:      6,
+			expected: "SELECT...",
+		},
+		{
+			name:     "cut before a multi-byte character",
+			sql:      "SELECT café",
+			max:      11,
+			expected: "SELECT caf...",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := truncateSQL(tt.sql, tt.max); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
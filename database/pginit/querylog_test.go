@@ -0,0 +1,160 @@
+package pginit_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/rs/zerolog"
+)
+
+func TestWithSlowQueryLog(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf)
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: 2,
+		},
+		pginit.WithLogger(&logger, "request-id"),
+		pginit.WithSlowQueryLog(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), "request-id", "req-1") // nolint: revive, staticcheck, nolintlint
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, "SELECT 'fast'"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if _, err := pool.Exec(ctx, "SELECT pg_sleep(0.1), 'secret-value', $1::text", "secret-arg"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	logs := buf.String()
+
+	if strings.Count(logs, "pginit: slow query") != 1 {
+		t.Fatalf("expected a single slow query logged but got: %s", logs)
+	}
+
+	for _, expected := range []string{`"sql":"SELECT pg_sleep(?), ?, $1::text"`, `"args":1`, `"rows":1`, `"request-id":"req-1"`} {
+		if !strings.Contains(logs, expected) {
+			t.Errorf("expected %s in logs but got: %s", expected, logs)
+		}
+	}
+
+	for _, secret := range []string{"secret-value", "secret-arg"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("expected %s not to be logged but got: %s", secret, logs)
+		}
+	}
+}
+
+func TestWithQueryStats(t *testing.T) {
+	t.Parallel()
+
+	stats := pginit.NewQueryStats()
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: 2,
+		},
+		pginit.WithQueryStats(stats),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgi.ConnPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		var n int
+		if err := pool.QueryRow(ctx, "SELECT $1::int + 1", i).Scan(&n); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+
+	if _, err := pool.Exec(ctx, "SELECT 1 / 0"); err == nil {
+		t.Fatal("expected division by zero error")
+	}
+
+	if _, err := pool.Exec(ctx, "SELECT 2 / 0"); err == nil {
+		t.Fatal("expected division by zero error")
+	}
+
+	snapshot := map[string]pginit.QueryStat{}
+	for _, stat := range stats.Snapshot() {
+		snapshot[stat.Fingerprint] = stat
+	}
+
+	tests := []struct {
+		fingerprint  string
+		expectCount  int64
+		expectErrors int64
+	}{
+		{fingerprint: "SELECT $1::int + ?", expectCount: 3, expectErrors: 0},
+		{fingerprint: "SELECT ? / ?", expectCount: 2, expectErrors: 2},
+	}
+
+	for _, tt := range tests {
+		stat, ok := snapshot[tt.fingerprint]
+		if !ok {
+			t.Errorf("expected stats for %q but got: %+v", tt.fingerprint, snapshot)
+
+			continue
+		}
+
+		if stat.Count != tt.expectCount || stat.Errors != tt.expectErrors {
+			t.Errorf("expected %d executions and %d errors for %q but got %+v",
+				tt.expectCount, tt.expectErrors, tt.fingerprint, stat)
+		}
+
+		if stat.P50 > stat.P99 || stat.P99 > stat.Max {
+			t.Errorf("expected p50 <= p99 <= max but got %+v", stat)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := stats.Dump(buf); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "SELECT ? / ?") {
+		t.Errorf("expected fingerprint in dump but got: %s", buf.String())
+	}
+
+	stats.Reset()
+
+	if len(stats.Snapshot()) != 0 {
+		t.Errorf("expected no stats after reset but got: %+v", stats.Snapshot())
+	}
+}
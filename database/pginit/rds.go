@@ -0,0 +1,135 @@
+package pginit
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// rdsTokenLifetime is how long an RDS IAM authentication token is valid.
+	rdsTokenLifetime = 15 * time.Minute
+	// rdsTokenRefreshMargin is how long before expiry a cached token is renewed.
+	rdsTokenRefreshMargin = time.Minute
+)
+
+// RDSTokenGenerator builds RDS IAM authentication tokens for dbUser on endpoint (host:port),
+// it is implemented with the AWS SDK by rdsauth.TokenGenerator.
+type RDSTokenGenerator interface {
+	BuildAuthToken(ctx context.Context, endpoint, region, dbUser string) (string, error)
+}
+
+// RDSIAMOption configures WithRDSIAMAuth behaviour.
+type RDSIAMOption func(*rdsIAMAuth)
+
+type rdsToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+type rdsIAMAuth struct {
+	region    string
+	generator RDSTokenGenerator
+	tlsConfig *tls.Config
+	noTLS     bool
+
+	mu     sync.Mutex
+	tokens map[string]rdsToken
+}
+
+// WithRDSIAMAuth authenticates every new connection with an RDS IAM authentication token
+// generated for Config.User by generator, e.g. rdsauth.NewTokenGenerator() using the default
+// AWS credentials chain, instead of Config.Password.
+// Tokens are cached per endpoint until shortly before they expire.
+// TLS is mandatory for IAM authentication, so it is forced with the server certificate
+// verified against the system roots, use WithRDSTLSConfig to trust the RDS CA bundle.
+func WithRDSIAMAuth(region string, generator RDSTokenGenerator, opts ...RDSIAMOption) Option {
+	return func(pgi *PGInit) {
+		rdsAuth := &rdsIAMAuth{
+			region:    region,
+			generator: generator,
+			tokens:    map[string]rdsToken{},
+		}
+
+		for _, opt := range opts {
+			opt(rdsAuth)
+		}
+
+		pgi.beforeConnect = append(pgi.beforeConnect, rdsAuth.beforeConnect)
+	}
+}
+
+// WithRDSTLSConfig set the TLS configuration, typically with RootCAs holding the RDS CA bundle.
+// ServerName is set to the host connected to if empty.
+func WithRDSTLSConfig(tlsConfig *tls.Config) RDSIAMOption {
+	return func(r *rdsIAMAuth) {
+		r.tlsConfig = tlsConfig
+	}
+}
+
+// WithoutRDSTLS does not force TLS, it must only be used to test against a local Postgres
+// with a fake RDSTokenGenerator.
+func WithoutRDSTLS() RDSIAMOption {
+	return func(r *rdsIAMAuth) {
+		r.noTLS = true
+	}
+}
+
+func (r *rdsIAMAuth) beforeConnect(ctx context.Context, connConfig *pgx.ConnConfig) error {
+	endpoint := net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port)))
+
+	token, err := r.token(ctx, endpoint, connConfig.User)
+	if err != nil {
+		return err
+	}
+
+	connConfig.Password = token
+
+	if r.noTLS {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if r.tlsConfig != nil {
+		tlsConfig = r.tlsConfig.Clone()
+	}
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = connConfig.Host
+	}
+
+	connConfig.TLSConfig = tlsConfig
+	// no plaintext fallback
+	connConfig.Fallbacks = nil
+
+	return nil
+}
+
+func (r *rdsIAMAuth) token(ctx context.Context, endpoint, user string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := user + "@" + endpoint
+
+	if cached, ok := r.tokens[key]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	token, err := r.generator.BuildAuthToken(ctx, endpoint, r.region, user)
+	if err != nil {
+		return "", fmt.Errorf("build rds auth token: %w", err)
+	}
+
+	r.tokens[key] = rdsToken{
+		token:     token,
+		expiresAt: time.Now().Add(rdsTokenLifetime - rdsTokenRefreshMargin),
+	}
+
+	return token, nil
+}
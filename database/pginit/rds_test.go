@@ -0,0 +1,98 @@
+package pginit_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+type fakeRDSTokenGenerator struct {
+	mu        sync.Mutex
+	calls     int
+	endpoints []string
+}
+
+func (g *fakeRDSTokenGenerator) BuildAuthToken(ctx context.Context, endpoint, region, dbUser string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.calls++
+	g.endpoints = append(g.endpoints, endpoint)
+
+	// the local postgres user password stands for the token
+	return "postgres", nil
+}
+
+func TestWithRDSIAMAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		opts          []pginit.RDSIAMOption
+		expectConnErr bool
+	}{
+		{
+			name:          "token used as password",
+			opts:          []pginit.RDSIAMOption{pginit.WithoutRDSTLS()},
+			expectConnErr: false,
+		},
+		{
+			name:          "tls forced without plaintext fallback",
+			opts:          nil,
+			expectConnErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			generator := &fakeRDSTokenGenerator{}
+
+			pgi, err := pginit.New(
+				&pginit.Config{
+					Host:     testHost,
+					Port:     testPort,
+					User:     "postgres",
+					Database: "datawarehouse",
+					MaxConns: 2,
+				},
+				pginit.WithRDSIAMAuth("ap-southeast-1", generator, tt.opts...),
+			)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			ctx := context.Background()
+
+			pool, err := pgi.ConnPool(ctx)
+			if (err != nil) != tt.expectConnErr {
+				t.Fatalf("expected connection error to be %t but got: %v", tt.expectConnErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+			defer pool.Close()
+
+			if err := pool.Ping(ctx); err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+
+			generator.mu.Lock()
+			defer generator.mu.Unlock()
+
+			if generator.calls != 1 {
+				t.Errorf("expected token generated once but got %d", generator.calls)
+			}
+
+			if generator.endpoints[0] != net.JoinHostPort(testHost, testPort) {
+				t.Errorf("expected endpoint %s but got %s", net.JoinHostPort(testHost, testPort), generator.endpoints[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package rdsauth generates RDS IAM authentication tokens with the AWS SDK for pginit.WithRDSIAMAuth.
+// It is separated from pginit so that only the services authenticating with IAM link the AWS SDK.
+//
+//	pgi, err := pginit.New(conf, pginit.WithRDSIAMAuth("ap-southeast-1", rdsauth.NewTokenGenerator()))
+package rdsauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+)
+
+// awsConfigTimeout bounds the loading of the AWS credentials chain.
+const awsConfigTimeout = 10 * time.Second
+
+// TokenGenerator signs tokens with the default AWS credentials chain, loaded on first use.
+// A failed load is retried by the next call. It implements pginit.RDSTokenGenerator.
+type TokenGenerator struct {
+	mu    sync.Mutex
+	creds aws.CredentialsProvider
+}
+
+// NewTokenGenerator returns a TokenGenerator.
+func NewTokenGenerator() *TokenGenerator {
+	return &TokenGenerator{}
+}
+
+// BuildAuthToken builds a token for dbUser on endpoint (host:port) signed for region.
+func (g *TokenGenerator) BuildAuthToken(ctx context.Context, endpoint, region, dbUser string) (string, error) {
+	creds, err := g.credentials(region)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := auth.BuildAuthToken(ctx, endpoint, region, dbUser, creds)
+	if err != nil {
+		return "", fmt.Errorf("build auth token: %w", err)
+	}
+
+	return token, nil
+}
+
+// credentials loads the credentials chain once, with its own context since it is shared
+// by all the connections and must not fail because the ctx of the first one is canceled.
+func (g *TokenGenerator) credentials(region string) (aws.CredentialsProvider, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.creds != nil {
+		return g.creds, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), awsConfigTimeout)
+	defer cancel()
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+
+	g.creds = cfg.Credentials
+
+	return g.creds, nil
+}
@@ -0,0 +1,34 @@
+package rdsauth_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monacohq/golang-common/database/pginit"
+	"github.com/monacohq/golang-common/database/pginit/rdsauth"
+)
+
+var _ pginit.RDSTokenGenerator = rdsauth.NewTokenGenerator()
+
+// nolint: paralleltest // the credentials are read from the environment
+func TestTokenGenerator(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	token, err := rdsauth.NewTokenGenerator().BuildAuthToken(
+		context.Background(), "db.example.com:5432", "ap-southeast-1", "iam_user",
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	for _, expected := range []string{"db.example.com:5432?Action=connect", "DBUser=iam_user", "X-Amz-Credential=AKIDEXAMPLE"} {
+		if !strings.Contains(token, expected) {
+			t.Errorf("expected %s in token but got: %s", expected, token)
+		}
+	}
+}
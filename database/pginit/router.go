@@ -0,0 +1,257 @@
+package pginit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// maxReplicaPingTimeout bounds the ping of a replica by its health check, startup included.
+const maxReplicaPingTimeout = 2 * time.Second
+
+// ReplicaStrategy defines how the Router picks a replica among the healthy ones.
+type ReplicaStrategy int
+
+const (
+	// RoundRobin spreads reads evenly across the healthy replicas.
+	RoundRobin ReplicaStrategy = iota
+	// LeastConns sends reads to the healthy replica with the fewest acquired connections.
+	LeastConns
+)
+
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	var val int32
+	if healthy {
+		val = 1
+	}
+
+	atomic.StoreInt32(&r.healthy, val)
+}
+
+// Router sends writes and transactions to the primary and reads to the replicas.
+// When every replica is down, reads fail over to the primary.
+type Router struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	strategy ReplicaStrategy
+	next     uint32
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// RouterPool initiates connections to the primary and the replicas listed in Config
+// and return a Router. The primary must be reachable, replicas that are not
+// are marked unhealthy until the health check sees them back.
+func (pgi *PGInit) RouterPool(ctx context.Context) (*Router, error) {
+	primary, err := pgi.ConnPool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+
+	router := &Router{
+		primary:  primary,
+		replicas: make([]*replica, 0, len(pgi.replicas)),
+		strategy: pgi.replicaStrategy,
+		stop:     make(chan struct{}),
+	}
+
+	for _, rep := range pgi.replicas {
+		repConf := pgi.pgxConf.Copy()
+		repConf.ConnConfig.Host = rep.Host
+		repConf.ConnConfig.Fallbacks = nil
+		// the health check decides if the replica is usable, not the startup
+		repConf.LazyConnect = true
+
+		port, err := strconv.ParseUint(portOrDefault(rep.Port), base10, bitSize16)
+		if err != nil {
+			router.Close()
+
+			return nil, fmt.Errorf("replica %s port: %w", rep.Host, err)
+		}
+
+		repConf.ConnConfig.Port = uint16(port)
+
+		if repConf.ConnConfig.TLSConfig != nil {
+			repConf.ConnConfig.TLSConfig = repConf.ConnConfig.TLSConfig.Clone()
+			repConf.ConnConfig.TLSConfig.ServerName = rep.Host
+		}
+
+		pool, err := pgi.connect(ctx, repConf)
+		if err != nil {
+			router.Close()
+
+			return nil, fmt.Errorf("replica %s: %w", rep.Host, err)
+		}
+
+		router.replicas = append(router.replicas, &replica{pool: pool})
+	}
+
+	router.checkReplicas(ctx, pgi.replicaHealthCheckPeriod)
+
+	if len(router.replicas) > 0 {
+		router.wg.Add(1)
+
+		go router.healthCheck(pgi.replicaHealthCheckPeriod)
+	}
+
+	return router, nil
+}
+
+func (r *Router) healthCheck(period time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkReplicas(context.Background(), period)
+		}
+	}
+}
+
+// checkReplicas pings the replicas in parallel, so that the timeouts of the unreachable ones
+// do not add up, and marks them healthy if they answer.
+func (r *Router) checkReplicas(ctx context.Context, period time.Duration) {
+	timeout := period
+	if timeout > maxReplicaPingTimeout {
+		timeout = maxReplicaPingTimeout
+	}
+
+	var wg sync.WaitGroup
+
+	for _, rep := range r.replicas {
+		wg.Add(1)
+
+		go func(rep *replica) {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			rep.setHealthy(rep.pool.Ping(WithoutTenant(pingCtx)) == nil)
+		}(rep)
+	}
+
+	wg.Wait()
+}
+
+// Primary returns the pool connected to the primary.
+func (r *Router) Primary() *pgxpool.Pool {
+	return r.primary
+}
+
+// Reader returns the pool of a healthy replica chosen by the ReplicaStrategy,
+// or the primary if no replica is healthy.
+func (r *Router) Reader() *pgxpool.Pool {
+	healthy := make([]*replica, 0, len(r.replicas))
+
+	for _, rep := range r.replicas {
+		if rep.isHealthy() {
+			healthy = append(healthy, rep)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return r.primary
+	}
+
+	if r.strategy == LeastConns {
+		chosen := healthy[0]
+
+		for _, rep := range healthy[1:] {
+			if rep.pool.Stat().AcquiredConns() < chosen.pool.Stat().AcquiredConns() {
+				chosen = rep
+			}
+		}
+
+		return chosen.pool
+	}
+
+	next := atomic.AddUint32(&r.next, 1)
+
+	return healthy[next%uint32(len(healthy))].pool
+}
+
+// Exec runs sql on the primary.
+func (r *Router) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := r.primary.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, fmt.Errorf("exec: %w", err)
+	}
+
+	return tag, nil
+}
+
+// Query runs sql on a replica.
+func (r *Router) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := r.Reader().Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return rows, nil
+}
+
+// QueryRow runs sql on a replica.
+func (r *Router) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.Reader().QueryRow(ctx, sql, args...)
+}
+
+// CopyFrom copies rowSrc into tableName on the primary.
+func (r *Router) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	copied, err := r.primary.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		return copied, fmt.Errorf("copy from: %w", err)
+	}
+
+	return copied, nil
+}
+
+// Begin starts a transaction on the primary.
+func (r *Router) Begin(ctx context.Context) (pgx.Tx, error) {
+	return r.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction with txOptions on the primary.
+func (r *Router) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := r.primary.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	return tx, nil
+}
+
+// Close stops the health check and closes the primary and replica pools, it can be called several times.
+func (r *Router) Close() {
+	r.closeOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+
+	for _, rep := range r.replicas {
+		rep.pool.Close()
+	}
+
+	r.primary.Close()
+}
@@ -0,0 +1,288 @@
+package pginit_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestRouterPool(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		replicas        []pginit.Replica
+		strategy        pginit.ReplicaStrategy
+		expectOnPrimary bool
+	}{
+		{
+			name:            "no replica reads from primary",
+			replicas:        nil,
+			strategy:        pginit.RoundRobin,
+			expectOnPrimary: true,
+		},
+		{
+			name:            "healthy replica with round robin",
+			replicas:        []pginit.Replica{{Host: testHost, Port: testPort}},
+			strategy:        pginit.RoundRobin,
+			expectOnPrimary: false,
+		},
+		{
+			name:            "healthy replica with least conns",
+			replicas:        []pginit.Replica{{Host: testHost, Port: testPort}},
+			strategy:        pginit.LeastConns,
+			expectOnPrimary: false,
+		},
+		{
+			name:            "every replica down fails over to primary",
+			replicas:        []pginit.Replica{{Host: "127.0.0.1", Port: "1"}},
+			strategy:        pginit.RoundRobin,
+			expectOnPrimary: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			pgi, err := pginit.New(
+				&pginit.Config{
+					Host:     testHost,
+					Port:     testPort,
+					User:     "postgres",
+					Password: "postgres",
+					Database: "datawarehouse",
+					MaxConns: 2,
+					Replicas: tt.replicas,
+				},
+				pginit.WithReplicaStrategy(tt.strategy),
+				pginit.WithReplicaHealthCheck(time.Second),
+				pginit.WithDecimalType(),
+				pginit.WithUUIDType(),
+			)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			router, err := pgi.RouterPool(ctx)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			defer router.Close()
+			// closing twice is allowed
+			t.Cleanup(router.Close)
+
+			if onPrimary := router.Reader() == router.Primary(); onPrimary != tt.expectOnPrimary {
+				t.Errorf("expected reads on primary to be %t but got %t", tt.expectOnPrimary, onPrimary)
+			}
+
+			var one int
+			if err := router.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil || one != 1 {
+				t.Errorf("expected 1 but got: %d, %v", one, err)
+			}
+
+			tx, err := router.Begin(ctx)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			defer tx.Rollback(ctx)
+
+			var readOnly string
+			if err := tx.QueryRow(ctx, "SHOW transaction_read_only").Scan(&readOnly); err != nil || readOnly != "off" {
+				t.Errorf("expected transaction on primary but got: %s, %v", readOnly, err)
+			}
+		})
+	}
+}
+
+// replicaProxy forwards TCP connections to the test database, standing for a replica
+// that can go down and come back on the same address.
+type replicaProxy struct {
+	t    *testing.T
+	addr string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+}
+
+func newReplicaProxy(t *testing.T) *replicaProxy {
+	t.Helper()
+
+	proxy := &replicaProxy{t: t, addr: "127.0.0.1:0"}
+	proxy.start()
+	t.Cleanup(proxy.stop)
+
+	return proxy
+}
+
+func (p *replicaProxy) replica() pginit.Replica {
+	host, port, _ := net.SplitHostPort(p.addr)
+
+	return pginit.Replica{Host: host, Port: port}
+}
+
+func (p *replicaProxy) start() {
+	p.t.Helper()
+
+	listener, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		p.t.Fatalf("expected no error but got: %v", err)
+	}
+
+	p.mu.Lock()
+	p.listener = listener
+	p.addr = listener.Addr().String()
+	p.mu.Unlock()
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server, err := net.Dial("tcp", net.JoinHostPort(testHost, testPort))
+			if err != nil {
+				_ = client.Close()
+
+				continue
+			}
+
+			p.mu.Lock()
+			p.conns = append(p.conns, client, server)
+			p.mu.Unlock()
+
+			go func() { _, _ = io.Copy(server, client) }()
+			go func() { _, _ = io.Copy(client, server) }()
+		}
+	}()
+}
+
+// stop closes the listener and the forwarded connections, like a replica going down.
+func (p *replicaProxy) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_ = p.listener.Close()
+
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+
+	p.conns = nil
+}
+
+func waitReader(t *testing.T, router *pginit.Router, onPrimary bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for (router.Reader() == router.Primary()) != onPrimary {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected reads on primary to be %t before timeout", onPrimary)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRouterPool_Failover(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	proxy := newReplicaProxy(t)
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: 2,
+			Replicas: []pginit.Replica{proxy.replica()},
+		},
+		pginit.WithReplicaHealthCheck(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	router, err := pgi.RouterPool(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer router.Close()
+
+	waitReader(t, router, false)
+
+	// the replica goes down: reads fail over to the primary
+	proxy.stop()
+	waitReader(t, router, true)
+
+	var one int
+	if err := router.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil || one != 1 {
+		t.Errorf("expected 1 but got: %d, %v", one, err)
+	}
+
+	// the replica comes back: reads go to it again
+	proxy.start()
+	waitReader(t, router, false)
+
+	if err := router.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil || one != 1 {
+		t.Errorf("expected 1 but got: %d, %v", one, err)
+	}
+}
+
+func TestRouterPool_UnreachableReplicas(t *testing.T) {
+	t.Parallel()
+
+	// non-routable addresses, a ping only ends with its timeout
+	replicas := []pginit.Replica{
+		{Host: "10.255.255.1", Port: "5432"},
+		{Host: "10.255.255.2", Port: "5432"},
+		{Host: "10.255.255.3", Port: "5432"},
+	}
+
+	pgi, err := pginit.New(
+		&pginit.Config{
+			Host:     testHost,
+			Port:     testPort,
+			User:     "postgres",
+			Password: "postgres",
+			Database: "datawarehouse",
+			MaxConns: 2,
+			Replicas: replicas,
+		},
+		pginit.WithReplicaHealthCheck(time.Second),
+	)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	start := time.Now()
+
+	router, err := pgi.RouterPool(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer router.Close()
+
+	// the replicas are pinged in parallel, not one period each
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the replicas to be pinged in parallel but startup took %v", elapsed)
+	}
+
+	if router.Reader() != router.Primary() {
+		t.Error("expected reads on primary")
+	}
+}
@@ -0,0 +1,232 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const tenantResetTimeout = 5 * time.Second
+
+// ErrMissingTenant is returned by TenantPool when the context has no tenant. A connection acquired
+// without tenant directly from the pool is closed instead, so that the queries run with it fail.
+var ErrMissingTenant = errors.New("missing tenant")
+
+// TenantMode selects how WithTenant isolates tenants.
+type TenantMode int
+
+const (
+	// TenantSchema sets the search_path to the schema named after the tenant.
+	TenantSchema TenantMode = iota
+	// TenantRole sets the role to the role named after the tenant, with SET ROLE.
+	TenantRole
+)
+
+type withoutTenantCtxKey struct{}
+
+// WithoutTenant returns a copy of ctx allowed to acquire connections without tenant, e.g. for
+// health checks and migrations, such connections keep the default search_path and role.
+// The pinging and background work of this module (Router, Health, Lock, migrate, outbox, jobqueue)
+// already use it, and still run in the tenant of ctx when it has one.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutTenantCtxKey{}, true)
+}
+
+// WithTenant isolates tenants by schema or role: when a connection is acquired, the search_path or the role
+// is set to the tenant found in the context under tenantKeyFromCtx, a string like the request id of WithLogger,
+// and it is reset when the connection is released.
+// A connection acquired without tenant, and not allowed by WithoutTenant, is closed so that
+// no query runs outside of a tenant, the error is logged with the logger given to WithLogger.
+// Use TenantPool to get ErrMissingTenant rather than the error of the closed connection.
+// The tenant is set for the session, so WithTenant cannot be used with WithPgBouncerMode.
+func WithTenant(tenantKeyFromCtx string, mode TenantMode) Option {
+	return func(pgi *PGInit) {
+		pgi.tenantKeyFromCtx = tenantKeyFromCtx
+
+		pgi.beforeAcquire = append(pgi.beforeAcquire, func(ctx context.Context, conn *pgx.Conn) bool {
+			if err := setTenant(ctx, conn, tenantKeyFromCtx, mode); err != nil {
+				if pgi.logger != nil {
+					pgi.logger.Error().Err(err).Msg("pginit: tenant")
+				}
+
+				// a false return would make the pool acquire another connection, forever
+				_ = conn.Close(ctx)
+			}
+
+			return true
+		})
+
+		pgi.afterRelease = append(pgi.afterRelease, func(conn *pgx.Conn) bool {
+			ctx, cancel := context.WithTimeout(context.Background(), tenantResetTimeout)
+			defer cancel()
+
+			return resetTenant(ctx, conn, mode) == nil
+		})
+	}
+}
+
+// tenantFromCtx returns the tenant of ctx, empty when ctx is allowed by WithoutTenant to have none.
+func tenantFromCtx(ctx context.Context, tenantKeyFromCtx string) (string, error) {
+	tenant, _ := ctx.Value(tenantKeyFromCtx).(string)
+	if tenant == "" {
+		if allowed, _ := ctx.Value(withoutTenantCtxKey{}).(bool); allowed {
+			return "", nil
+		}
+
+		return "", ErrMissingTenant
+	}
+
+	return tenant, nil
+}
+
+func setTenant(ctx context.Context, conn *pgx.Conn, tenantKeyFromCtx string, mode TenantMode) error {
+	tenant, err := tenantFromCtx(ctx, tenantKeyFromCtx)
+	if err != nil || tenant == "" {
+		return err
+	}
+
+	switch mode {
+	case TenantRole:
+		_, err = conn.Exec(ctx, "SELECT set_config('role', $1, false)", tenant)
+	default:
+		_, err = conn.Exec(ctx, "SELECT set_config('search_path', $1, false)", pgx.Identifier{tenant}.Sanitize())
+	}
+
+	if err != nil {
+		return fmt.Errorf("set tenant %s: %w", tenant, err)
+	}
+
+	return nil
+}
+
+func resetTenant(ctx context.Context, conn *pgx.Conn, mode TenantMode) error {
+	sql := "RESET search_path"
+	if mode == TenantRole {
+		sql = "RESET ROLE"
+	}
+
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("reset tenant: %w", err)
+	}
+
+	return nil
+}
+
+// TenantPool runs queries on a pool of a PGInit configured WithTenant, failing them with ErrMissingTenant
+// when ctx has no tenant instead of acquiring a connection that would be closed.
+// It implements Execer, Querier, TxBeginner, CopyFromer and Acquirer.
+type TenantPool struct {
+	pool             *pgxpool.Pool
+	tenantKeyFromCtx string
+}
+
+// TenantPool wraps pool, created by pgi, in a TenantPool. Without WithTenant, it does not check ctx.
+func (pgi *PGInit) TenantPool(pool *pgxpool.Pool) *TenantPool {
+	return &TenantPool{pool: pool, tenantKeyFromCtx: pgi.tenantKeyFromCtx}
+}
+
+// Pool returns the underlying pool.
+func (p *TenantPool) Pool() *pgxpool.Pool {
+	return p.pool
+}
+
+func (p *TenantPool) check(ctx context.Context) error {
+	if p.tenantKeyFromCtx == "" {
+		return nil
+	}
+
+	_, err := tenantFromCtx(ctx, p.tenantKeyFromCtx)
+
+	return err
+}
+
+// Acquire returns a connection set to the tenant of ctx.
+func (p *TenantPool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if err := p.check(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Exec runs sql in the tenant of ctx.
+func (p *TenantPool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := p.check(ctx); err != nil {
+		return nil, err
+	}
+
+	tag, err := p.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, fmt.Errorf("exec: %w", err)
+	}
+
+	return tag, nil
+}
+
+// Query runs sql in the tenant of ctx.
+func (p *TenantPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := p.check(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return rows, nil
+}
+
+// QueryRow runs sql in the tenant of ctx, ErrMissingTenant is returned by Scan.
+func (p *TenantPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := p.check(ctx); err != nil {
+		return errRow{err: err}
+	}
+
+	return p.pool.QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction in the tenant of ctx.
+func (p *TenantPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction with txOptions in the tenant of ctx.
+func (p *TenantPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if err := p.check(ctx); err != nil {
+		return nil, err
+	}
+
+	tx, err := p.pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	return tx, nil
+}
+
+// CopyFrom copies rowSrc into tableName in the tenant of ctx.
+func (p *TenantPool) CopyFrom(
+	ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	if err := p.check(ctx); err != nil {
+		return 0, err
+	}
+
+	copied, err := p.pool.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		return copied, fmt.Errorf("copy from: %w", err)
+	}
+
+	return copied, nil
+}
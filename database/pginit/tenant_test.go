@@ -0,0 +1,119 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+func TestWithTenant(t *testing.T) {
+	t.Parallel()
+
+	ctx := pginit.WithoutTenant(context.Background())
+	pool := newTestPoolWithMaxConns(t, 1, pginit.WithTenant("tenant", pginit.TenantSchema))
+
+	for _, tenant := range []string{"tenant_a", "tenant_b"} {
+		if _, err := pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+tenant); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if _, err := pool.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+tenant+".accounts AS SELECT '"+tenant+"' AS name"); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+
+	for _, tenant := range []string{"tenant_a", "tenant_b"} {
+		tenantCtx := context.WithValue(context.Background(), "tenant", tenant) // nolint: revive, staticcheck, nolintlint
+
+		var name string
+		if err := pool.QueryRow(tenantCtx, "SELECT name FROM accounts").Scan(&name); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+
+		if name != tenant {
+			t.Errorf("expected %s but got %s", tenant, name)
+		}
+	}
+
+	// the single connection is reset when released
+	var searchPath string
+	if err := pool.QueryRow(ctx, "SHOW search_path").Scan(&searchPath); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if searchPath != `"$user", public` {
+		t.Errorf("expected default search_path but got %s", searchPath)
+	}
+
+	var one int
+	if err := pool.QueryRow(context.Background(), "SELECT 1").Scan(&one); err == nil {
+		t.Error("expected error without tenant")
+	}
+}
+
+func TestWithTenant_Role(t *testing.T) {
+	t.Parallel()
+
+	ctx := pginit.WithoutTenant(context.Background())
+	pool := newTestPoolWithMaxConns(t, 1, pginit.WithTenant("tenant", pginit.TenantRole))
+
+	if _, err := pool.Exec(ctx,
+		"DO $$ BEGIN CREATE ROLE tenant_role_a; EXCEPTION WHEN duplicate_object THEN NULL; END $$",
+	); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	tenantCtx := context.WithValue(context.Background(), "tenant", "tenant_role_a") // nolint: revive, staticcheck, nolintlint
+
+	var user string
+	if err := pool.QueryRow(tenantCtx, "SELECT current_user").Scan(&user); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if user != "tenant_role_a" {
+		t.Errorf("expected tenant_role_a but got %s", user)
+	}
+
+	// the single connection is reset when released
+	if err := pool.QueryRow(ctx, "SELECT current_user").Scan(&user); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if user != "postgres" {
+		t.Errorf("expected postgres but got %s", user)
+	}
+}
+
+func TestTenantPool(t *testing.T) {
+	t.Parallel()
+
+	pgi := newTestPGInit(t, pginit.WithTenant("tenant", pginit.TenantSchema))
+
+	pool, err := pgi.ConnPool(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer pool.Close()
+
+	tenantPool := pgi.TenantPool(pool)
+
+	var one int
+	if err := tenantPool.QueryRow(context.Background(), "SELECT 1").Scan(&one); !errors.Is(err, pginit.ErrMissingTenant) {
+		t.Errorf("expected ErrMissingTenant but got: %v", err)
+	}
+
+	if _, err := tenantPool.Exec(context.Background(), "SELECT 1"); !errors.Is(err, pginit.ErrMissingTenant) {
+		t.Errorf("expected ErrMissingTenant but got: %v", err)
+	}
+
+	if _, err := tenantPool.Exec(pginit.WithoutTenant(context.Background()), "SELECT 1"); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+
+	// the internal pings run without tenant
+	if _, err := pginit.Health(context.Background(), pool, pginit.HealthOptions{}); err != nil {
+		t.Errorf("expected healthy pool but got: %v", err)
+	}
+}
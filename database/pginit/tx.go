@@ -0,0 +1,157 @@
+package pginit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	defaultTxMaxAttempts = 3
+	defaultTxBackoff     = 50 * time.Millisecond
+
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// TxBeginner starts transactions, it is implemented by *pgxpool.Pool, *pgx.Conn and *Router.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxOptions configures InTx.
+type TxOptions struct {
+	pgx.TxOptions
+	// MaxAttempts is the number of times the transaction is run before giving up
+	// on serialization failures and deadlocks, default 3.
+	MaxAttempts int
+	// Backoff is the initial delay between attempts, doubled and jittered at each retry, default 50ms.
+	Backoff time.Duration
+}
+
+type txCtxKey struct{}
+
+// ContextWithTx returns a copy of ctx carrying tx, so that InTx called with it
+// runs in a savepoint of tx instead of starting a new transaction.
+func ContextWithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txCtxKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txCtxKey{}).(pgx.Tx)
+
+	return tx, ok
+}
+
+// InTx runs fn in a transaction started on db, committed if fn returns nil
+// and rolled back if it returns an error or panics.
+// Serialization failures (40001) and deadlocks (40P01) make the whole transaction
+// run again, with backoff, up to opts.MaxAttempts times.
+// If ctx already carries a transaction (see ContextWithTx), fn runs in a savepoint of it
+// and is never retried on its own: only the outermost transaction can be.
+// The ctx given to fn carries tx, so that InTx called with it, directly or by the functions
+// fn calls, joins the transaction:
+//
+//	err := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+//		if _, err := tx.Exec(ctx, "INSERT INTO orders ..."); err != nil {
+//			return err
+//		}
+//
+//		// runs in a savepoint of tx
+//		return reserveStock(ctx, pool, order)
+//	})
+func InTx(ctx context.Context, db TxBeginner, opts TxOptions, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return runTx(ctx, func(ctx context.Context) (pgx.Tx, error) {
+			savepoint, err := tx.Begin(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("savepoint: %w", err)
+			}
+
+			return savepoint, nil
+		}, fn)
+	}
+
+	maxAttempts := defaultTxMaxAttempts
+	if opts.MaxAttempts > 0 {
+		maxAttempts = opts.MaxAttempts
+	}
+
+	backoff := defaultTxBackoff
+	if opts.Backoff > 0 {
+		backoff = opts.Backoff
+	}
+
+	begin := func(ctx context.Context) (pgx.Tx, error) {
+		tx, err := db.BeginTx(ctx, opts.TxOptions)
+		if err != nil {
+			return nil, fmt.Errorf("begin tx: %w", err)
+		}
+
+		return tx, nil
+	}
+
+	var err error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if errWait := sleepContext(ctx, jitteredBackoff(backoff, attempt-1)); errWait != nil {
+				return fmt.Errorf("in tx after %d attempts: %w", attempt, err)
+			}
+		}
+
+		err = runTx(ctx, begin, fn)
+		if !isRetryableTxError(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("in tx after %d attempts: %w", maxAttempts, err)
+}
+
+func runTx(
+	ctx context.Context,
+	begin func(ctx context.Context) (pgx.Tx, error),
+	fn func(ctx context.Context, tx pgx.Tx) error,
+) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+
+			panic(p)
+		}
+	}()
+
+	if err := fn(ContextWithTx(ctx, tx), tx); err != nil {
+		if errRollback := tx.Rollback(ctx); errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
+			return fmt.Errorf("%w (rollback: %s)", err, errRollback.Error())
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
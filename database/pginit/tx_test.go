@@ -0,0 +1,192 @@
+package pginit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/monacohq/golang-common/database/pginit"
+)
+
+var errTxTest = errors.New("tx test error")
+
+func countRows(t *testing.T, pool *pgxpool.Pool, table string) int {
+	t.Helper()
+
+	var count int
+	if err := pool.QueryRow(context.Background(), "SELECT count(*) FROM "+table).Scan(&count); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	return count
+}
+
+func TestInTx(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		table          string
+		failures       int
+		fnErr          error
+		expectErr      error
+		expectAttempts int
+		expectRows     int
+	}{
+		{
+			name:           "commit on success",
+			table:          "in_tx_commit",
+			expectAttempts: 1,
+			expectRows:     1,
+		},
+		{
+			name:           "rollback on error",
+			table:          "in_tx_rollback",
+			fnErr:          errTxTest,
+			expectErr:      errTxTest,
+			expectAttempts: 1,
+			expectRows:     0,
+		},
+		{
+			name:           "retry on serialization failure",
+			table:          "in_tx_retry",
+			failures:       2,
+			expectAttempts: 3,
+			expectRows:     1,
+		},
+		{
+			name:           "give up after max attempts",
+			table:          "in_tx_give_up",
+			failures:       5,
+			expectErr:      &pgconn.PgError{Code: "40001"},
+			expectAttempts: 3,
+			expectRows:     0,
+		},
+	}
+
+	pool := newTestPool(t)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			if _, err := pool.Exec(ctx, "CREATE TABLE "+tt.table+"(id int)"); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+
+			attempts := 0
+
+			err := pginit.InTx(ctx, pool, pginit.TxOptions{MaxAttempts: 3, Backoff: time.Millisecond}, func(ctx context.Context, tx pgx.Tx) error {
+				attempts++
+
+				if _, err := tx.Exec(ctx, "INSERT INTO "+tt.table+" VALUES(1)"); err != nil {
+					return err
+				}
+
+				if attempts <= tt.failures {
+					return &pgconn.PgError{Code: "40001"}
+				}
+
+				return tt.fnErr
+			})
+
+			var pgErr *pgconn.PgError
+
+			switch {
+			case tt.expectErr == nil && err != nil:
+				t.Errorf("expected no error but got: %v", err)
+			case errors.As(tt.expectErr, &pgErr) && !errors.As(err, &pgErr):
+				t.Errorf("expected pg error but got: %v", err)
+			case tt.expectErr != nil && !errors.As(tt.expectErr, &pgErr) && !errors.Is(err, tt.expectErr):
+				t.Errorf("expected (%v) but got (%v)", tt.expectErr, err)
+			}
+
+			if attempts != tt.expectAttempts {
+				t.Errorf("expected %d attempts but got %d", tt.expectAttempts, attempts)
+			}
+
+			if rows := countRows(t, pool, tt.table); rows != tt.expectRows {
+				t.Errorf("expected %d rows but got %d", tt.expectRows, rows)
+			}
+		})
+	}
+}
+
+func TestInTx_Panic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	if _, err := pool.Exec(ctx, "CREATE TABLE in_tx_panic(id int)"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Error("expected panic to be propagated")
+			}
+		}()
+
+		_ = pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "INSERT INTO in_tx_panic VALUES(1)"); err != nil {
+				return err
+			}
+
+			panic("boom")
+		})
+	}()
+
+	if rows := countRows(t, pool, "in_tx_panic"); rows != 0 {
+		t.Errorf("expected 0 rows but got %d", rows)
+	}
+}
+
+func TestInTx_Savepoint(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	pool := newTestPool(t)
+
+	if _, err := pool.Exec(ctx, "CREATE TABLE in_tx_savepoint(id int)"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	err := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "INSERT INTO in_tx_savepoint VALUES(1)"); err != nil {
+			return err
+		}
+
+		errNested := pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "INSERT INTO in_tx_savepoint VALUES(2)"); err != nil {
+				return err
+			}
+
+			return errTxTest
+		})
+		if !errors.Is(errNested, errTxTest) {
+			t.Errorf("expected (%v) but got (%v)", errTxTest, errNested)
+		}
+
+		return pginit.InTx(ctx, pool, pginit.TxOptions{}, func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, "INSERT INTO in_tx_savepoint VALUES(3)")
+
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	if rows := countRows(t, pool, "in_tx_savepoint"); rows != 2 {
+		t.Errorf("expected 2 rows but got %d", rows)
+	}
+}